@@ -4,13 +4,49 @@ import (
 	"context"
 	"flag"
 	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/alert"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/anomaly"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/cohort"
 	"github.com/rzetelskik/allezon-analytics/collector/internal/collector"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/cooccurrence"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/funnel"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/path"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/segment"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/session"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/action"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/watermark"
 	"k8s.io/klog/v2"
 	"log"
 	"os"
 	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	actions = flag.String("actions", "", "path to the file listing the actions tracked besides VIEW, BUY and REFUND, user tags of other actions are counted but left out of heavy hitters")
+
+	allowedLateness = flag.Duration("allowed-lateness", 5*time.Minute, "how far behind the maximum observed event time a user tag can be before it's considered late")
+	latePolicy      = flag.String("late-policy", string(watermark.LatePolicyCount), "what to do with late user tags: 'drop' discards them, 'count' aggregates them separately")
+	topKCapacity    = flag.Int("top-k-capacity", 64, "number of items tracked by each per-bucket heavy hitter sketch, sketches are disabled if not positive")
+
+	cooccurrenceWindow   = flag.Duration("cooccurrence-window", 24*time.Hour, "how long co-occurrences are counted for")
+	cooccurrenceCapacity = flag.Int("cooccurrence-capacity", 50, "number of co-occurring products tracked per product and hour")
+
+	pathWindow   = flag.Duration("path-window", 7*24*time.Hour, "how long paths leading to purchases are counted for")
+	pathCapacity = flag.Int("path-capacity", 50, "number of paths tracked per target, length and hour")
+
+	alertRules           = flag.String("alert-rules", "", "path to the file with the threshold alerting rules, alerting is disabled if empty")
+	alertEvaluationDelay = flag.Duration("alert-evaluation-delay", time.Minute, "how far behind the watermark buckets are evaluated against the alerting rules")
+	alertWebhookTimeout  = flag.Duration("alert-webhook-timeout", 5*time.Second, "timeout of alert webhook requests")
+
+	anomalySeries          = flag.String("anomaly-series", "", "path to the file with the series watched for anomalies, anomaly detection is disabled if empty")
+	anomalyZScore          = flag.Float64("anomaly-z-score", 3, "how many standard deviations from the baseline a bucket has to be to be flagged as an anomaly")
+	anomalyAlpha           = flag.Float64("anomaly-alpha", 0.1, "weight of the newest bucket in the exponentially weighted baseline")
+	anomalyWarmUp          = flag.Int("anomaly-warm-up", 30, "number of buckets a baseline needs before it's used for detection")
+	anomalyEvaluationDelay = flag.Duration("anomaly-evaluation-delay", time.Minute, "how far behind the watermark buckets are checked for anomalies")
 )
 
 func main() {
@@ -27,21 +63,135 @@ func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	log.SetOutput(os.Stdout)
 
-	g := goka.DefineGroup(kafka.SinkGroup,
-		goka.Input(kafka.AggregateTopic, new(api.UserTagCodec), collector.Collect),
-		goka.Persist(new(api.UserAggregatesCodec)),
-	)
+	if len(*actions) != 0 {
+		_, err = action.Load(*actions)
+		if err != nil {
+			klog.Fatalf("can't load actions: %v", err)
+		}
+	}
 
-	p, err := goka.NewProcessor(
-		[]string{kafka.Bootstrap},
-		g,
-	)
+	lp, err := watermark.ParseLatePolicy(*latePolicy)
 	if err != nil {
-		klog.Fatalf("can't create new processor: %v", err)
+		klog.Fatalf("can't parse late policy: %v", err)
 	}
 
-	err = p.Run(context.Background())
-	if err != nil {
-		klog.Fatalf("can't run processor: %v", err)
+	c := collector.NewCollector(kafka.SinkGroup, *allowedLateness, lp, *topKCapacity)
+	at := collector.NewCollector(kafka.AttributionSinkGroup, *allowedLateness, lp, 0)
+	co := cooccurrence.NewCollector(*cooccurrenceWindow, *cooccurrenceCapacity)
+	pc := path.NewCollector(*pathWindow, *pathCapacity)
+
+	groups := []*goka.GroupGraph{
+		goka.DefineGroup(kafka.SinkGroup,
+			goka.Input(kafka.AggregateTopic, new(api.UserTagCodec), c.Collect),
+			goka.Output(kafka.WatermarkTopic, new(api.WatermarkCodec)),
+			goka.Persist(new(api.UserAggregatesCodec)),
+		),
+		goka.DefineGroup(kafka.AttributionSinkGroup,
+			goka.Input(kafka.AttributionTopic, new(api.UserTagCodec), at.Collect),
+			goka.Output(kafka.WatermarkTopic, new(api.WatermarkCodec)),
+			goka.Persist(new(api.UserAggregatesCodec)),
+		),
+		goka.DefineGroup(kafka.WatermarkGroup,
+			goka.Input(kafka.WatermarkTopic, new(api.WatermarkCodec), collector.MergeWatermarks),
+			goka.Persist(new(api.WatermarksCodec)),
+		),
+		goka.DefineGroup(kafka.FunnelSinkGroup,
+			goka.Input(kafka.FunnelTopic, new(api.FunnelEventCodec), funnel.Collect),
+			goka.Persist(new(api.FunnelAggregatesCodec)),
+		),
+		goka.DefineGroup(kafka.SessionSinkGroup,
+			goka.Input(kafka.SessionTopic, new(api.SessionEventCodec), session.Collect),
+			goka.Persist(new(api.SessionAggregatesCodec)),
+		),
+		goka.DefineGroup(kafka.CooccurrenceSinkGroup,
+			goka.Input(kafka.CooccurrenceTopic, new(api.CooccurrenceEventCodec), co.Collect),
+			goka.Persist(new(api.CooccurrencesCodec)),
+		),
+		goka.DefineGroup(kafka.AnomalySinkGroup,
+			goka.Input(kafka.AnomalyTopic, new(api.AnomalyCodec), anomaly.Collect),
+			goka.Persist(new(api.AnomaliesCodec)),
+		),
+		goka.DefineGroup(kafka.CohortSinkGroup,
+			goka.Input(kafka.CohortTopic, new(api.CohortEventCodec), cohort.Collect),
+			goka.Persist(new(api.CohortAggregatesCodec)),
+		),
+		goka.DefineGroup(kafka.PathSinkGroup,
+			goka.Input(kafka.PathTopic, new(api.PathEventCodec), pc.Collect),
+			goka.Persist(new(api.PathsCodec)),
+		),
+		goka.DefineGroup(kafka.SegmentSinkGroup,
+			goka.Input(kafka.SegmentTopic, new(api.SegmentEventCodec), segment.Collect),
+			goka.Persist(new(api.SegmentMemberCodec)),
+		),
+	}
+
+	if len(*alertRules) != 0 {
+		rules, err := alert.LoadRules(*alertRules)
+		if err != nil {
+			klog.Fatalf("can't load alerting rules: %v", err)
+		}
+
+		e := alert.NewEngine(rules, *alertEvaluationDelay)
+		n := alert.NewNotifier(rules, *alertWebhookTimeout)
+
+		groups = append(groups,
+			goka.DefineGroup(kafka.AlertGroup,
+				goka.Input(kafka.WatermarkTopic, new(api.WatermarkCodec), e.Evaluate),
+				goka.Lookup(kafka.SinkTable, new(api.UserAggregatesCodec)),
+				goka.Output(kafka.AlertTopic, new(api.AlertCodec)),
+				goka.Persist(new(api.AlertStateCodec)),
+			),
+			goka.DefineGroup(kafka.AlertNotifyGroup,
+				goka.Input(kafka.AlertTopic, new(api.AlertCodec), n.Notify),
+			),
+		)
+	}
+
+	if len(*anomalySeries) != 0 {
+		series, err := anomaly.LoadSeries(*anomalySeries)
+		if err != nil {
+			klog.Fatalf("can't load anomaly series: %v", err)
+		}
+
+		if *anomalyAlpha <= 0 || *anomalyAlpha > 1 {
+			klog.Fatalf("anomaly alpha has to be within (0, 1]")
+		}
+
+		d := anomaly.NewDetector(series, *anomalyAlpha, *anomalyZScore, *anomalyWarmUp, *anomalyEvaluationDelay)
+
+		groups = append(groups,
+			goka.DefineGroup(kafka.AnomalyGroup,
+				goka.Input(kafka.WatermarkTopic, new(api.WatermarkCodec), d.Detect),
+				goka.Lookup(kafka.SinkTable, new(api.UserAggregatesCodec)),
+				goka.Output(kafka.AnomalyTopic, new(api.AnomalyCodec)),
+				goka.Persist(new(api.AnomalyStateCodec)),
+			),
+		)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, g := range groups {
+		p, err := goka.NewProcessor(
+			[]string{kafka.Bootstrap},
+			g,
+		)
+		if err != nil {
+			klog.Fatalf("can't create new processor: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := p.Run(ctx)
+			if err != nil {
+				klog.Fatalf("can't run processor %s: %v", p.Graph().Group(), err)
+			}
+		}()
 	}
 }
@@ -0,0 +1,112 @@
+package alert
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// Engine evaluates the rules against the collector's aggregates as its watermark closes buckets.
+// The watermarks of the collector all share a key, so a single partition holds the state of every rule.
+type Engine struct {
+	rules []Rule
+	// delay holds back the evaluation of closed buckets to let the collector table's changelog catch up.
+	delay time.Duration
+}
+
+func NewEngine(rules []Rule, delay time.Duration) *Engine {
+	return &Engine{
+		rules: rules,
+		delay: delay,
+	}
+}
+
+func (e *Engine) Evaluate(ctx goka.Context, msg interface{}) {
+	var as api.AlertState
+
+	v := ctx.Value()
+	if v != nil {
+		as = v.(api.AlertState)
+	}
+
+	w, ok := msg.(api.Watermark)
+	if !ok {
+		klog.Errorf("received message's type is not of type Watermark")
+		return
+	}
+
+	if ctx.Key() != string(kafka.SinkGroup) {
+		return
+	}
+
+	if as.Rules == nil {
+		as.Rules = make(map[string]api.AlertRuleState)
+	}
+
+	for _, b := range as.Clock.Advance(w, e.delay) {
+		for i := range e.rules {
+			r := &e.rules[i]
+
+			ua := api.UserAggregates{}
+			v := ctx.Lookup(kafka.SinkTable, r.Key(b))
+			if v != nil {
+				ua = v.(api.UserAggregates)
+			}
+
+			rs := as.Rules[r.ID]
+			a := evaluate(&rs, r, b, r.Value(ua))
+			as.Rules[r.ID] = rs
+
+			if a != nil {
+				klog.V(2).InfoS("alert", "rule", a.Rule, "status", a.Status, "bucket", a.Bucket, "value", a.Value)
+				ctx.Emit(kafka.AlertTopic, a.Rule, *a)
+			}
+		}
+	}
+
+	ctx.SetValue(as)
+}
+
+// evaluate advances the state of the rule by a closed bucket and returns the alert it triggers, if any.
+// A rule fires once per breach and resolves with the first bucket that doesn't breach it.
+func evaluate(rs *api.AlertRuleState, r *Rule, bucket time.Time, value int64) *api.Alert {
+	if !r.breached(value) {
+		fired := rs.Firing
+		since := rs.Since
+		*rs = api.AlertRuleState{}
+
+		if !fired {
+			return nil
+		}
+
+		return &api.Alert{
+			Rule:      r.ID,
+			Status:    api.RESOLVED,
+			Bucket:    bucket,
+			Value:     value,
+			Threshold: r.Threshold,
+			Since:     since,
+		}
+	}
+
+	if rs.Breaches == 0 {
+		rs.Since = bucket
+	}
+	rs.Breaches++
+
+	if rs.Firing || rs.Breaches < r.buckets {
+		return nil
+	}
+	rs.Firing = true
+
+	return &api.Alert{
+		Rule:      r.ID,
+		Status:    api.FIRING,
+		Bucket:    bucket,
+		Value:     value,
+		Threshold: r.Threshold,
+		Since:     rs.Since,
+	}
+}
@@ -0,0 +1,107 @@
+package alert
+
+import (
+	"github.com/rzetelskik/allezon-analytics/collector/internal/series"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	ts := []struct {
+		name     string
+		rule     Rule
+		values   []int64
+		expected []api.Alert
+	}{
+		{
+			name:     "Rule within the threshold doesn't fire",
+			rule:     Rule{Series: series.Series{ID: "low-buys"}, Operator: BELOW, Threshold: 10, buckets: 2},
+			values:   []int64{10, 12, 10},
+			expected: []api.Alert{},
+		},
+		{
+			name:     "Rule fires once the threshold is breached for long enough and fires only once",
+			rule:     Rule{Series: series.Series{ID: "low-buys"}, Operator: BELOW, Threshold: 10, buckets: 2},
+			values:   []int64{9, 10, 9, 8, 7},
+			expected: []api.Alert{{Rule: "low-buys", Status: api.FIRING, Bucket: base.Add(3 * time.Minute), Value: 8, Threshold: 10, Since: base.Add(2 * time.Minute)}},
+		},
+		{
+			name:   "Firing rule resolves with the first bucket within the threshold",
+			rule:   Rule{Series: series.Series{ID: "high-revenue"}, Operator: ABOVE, Threshold: 100, buckets: 1},
+			values: []int64{101, 150, 100, 90},
+			expected: []api.Alert{
+				{Rule: "high-revenue", Status: api.FIRING, Bucket: base, Value: 101, Threshold: 100, Since: base},
+				{Rule: "high-revenue", Status: api.RESOLVED, Bucket: base.Add(2 * time.Minute), Value: 100, Threshold: 100, Since: base},
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			rs := api.AlertRuleState{}
+			res := make([]api.Alert, 0)
+			for i, v := range test.values {
+				a := evaluate(&rs, &test.rule, base.Add(time.Duration(i)*time.Minute), v)
+				if a != nil {
+					res = append(res, *a)
+				}
+			}
+
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %v != %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	ts := []struct {
+		name        string
+		data        string
+		expectedErr bool
+	}{
+		{
+			name:        "Valid rule is loaded",
+			data:        `{"rules": [{"id": "low-buys", "action": "BUY", "aggregate": "COUNT", "filters": {"origin": "X"}, "operator": "below", "threshold": 10, "for": "5m"}]}`,
+			expectedErr: false,
+		},
+		{
+			name:        "Unknown dimension is rejected",
+			data:        `{"rules": [{"id": "low-buys", "action": "BUY", "aggregate": "COUNT", "filters": {"color": "red"}, "operator": "below", "threshold": 10}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Duration which isn't a multiple of a minute is rejected",
+			data:        `{"rules": [{"id": "low-buys", "action": "BUY", "aggregate": "COUNT", "operator": "below", "threshold": 10, "for": "90s"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Duplicate rules are rejected",
+			data:        `{"rules": [{"id": "a", "action": "BUY", "aggregate": "COUNT", "operator": "below", "threshold": 10}, {"id": "a", "action": "VIEW", "aggregate": "COUNT", "operator": "above", "threshold": 10}]}`,
+			expectedErr: true,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			err := os.WriteFile(path, []byte(test.data), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = LoadRules(path)
+			if (err != nil) != test.expectedErr {
+				t.Errorf("expected error: %v, got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+	"net/http"
+	"time"
+)
+
+// Notifier delivers the alerts of the rules to their webhooks.
+type Notifier struct {
+	webhooks map[string][]string
+	client   *http.Client
+}
+
+func NewNotifier(rules []Rule, timeout time.Duration) *Notifier {
+	webhooks := make(map[string][]string, len(rules))
+	for _, r := range rules {
+		webhooks[r.ID] = r.Webhooks
+	}
+
+	return &Notifier{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (n *Notifier) Notify(ctx goka.Context, msg interface{}) {
+	a, ok := msg.(api.Alert)
+	if !ok {
+		klog.Errorf("received message's type is not of type Alert")
+		return
+	}
+
+	for _, url := range n.webhooks[a.Rule] {
+		err := n.post(url, a)
+		if err != nil {
+			klog.ErrorS(err, "can't notify webhook", "rule", a.Rule, "url", url)
+		}
+	}
+}
+
+func (n *Notifier) post(url string, a api.Alert) error {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("can't marshal alert: %w", err)
+	}
+
+	resp, err := n.client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("can't post alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package alert
+
+import (
+	"encoding/json"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/series"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNotifierPost(t *testing.T) {
+	received := make(chan api.Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a api.Alert
+		err := json.NewDecoder(r.Body).Decode(&a)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- a
+	}))
+	defer srv.Close()
+
+	n := NewNotifier([]Rule{{Series: series.Series{ID: "low-buys"}, Webhooks: []string{srv.URL}}}, time.Second)
+
+	expected := api.Alert{
+		Rule:      "low-buys",
+		Status:    api.FIRING,
+		Bucket:    time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC),
+		Value:     8,
+		Threshold: 10,
+		Since:     time.Date(2022, 3, 22, 11, 56, 0, 0, time.UTC),
+	}
+	err := n.post(n.webhooks["low-buys"][0], expected)
+	if err != nil {
+		t.Fatalf("can't post alert: %v", err)
+	}
+
+	res := <-received
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected and received alerts differ: %v != %v", expected, res)
+	}
+}
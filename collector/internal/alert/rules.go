@@ -0,0 +1,95 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/series"
+	"os"
+	"time"
+)
+
+type Operator string
+
+const (
+	ABOVE Operator = "above"
+	BELOW Operator = "below"
+)
+
+// Rule fires once the aggregate of a series stays above or below the threshold for the given number of closed buckets.
+type Rule struct {
+	series.Series
+	Operator  Operator `json:"operator"`
+	Threshold int64    `json:"threshold"`
+	// For is how long the threshold has to be breached for the rule to fire, e.g. "5m". Defaults to a single bucket.
+	For string `json:"for,omitempty"`
+	// Webhooks are notified about the alerts of the rule in addition to the alerts topic.
+	Webhooks []string `json:"webhooks,omitempty"`
+
+	buckets int
+}
+
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules reads and validates the rules stored in the file at path.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read rules file: %w", err)
+	}
+
+	var rf rulesFile
+	err = json.Unmarshal(data, &rf)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal rules file: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	for i := range rf.Rules {
+		err = rf.Rules[i].init()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rf.Rules[i].ID, err)
+		}
+
+		if ids[rf.Rules[i].ID] {
+			return nil, fmt.Errorf("duplicate rule %q", rf.Rules[i].ID)
+		}
+		ids[rf.Rules[i].ID] = true
+	}
+
+	return rf.Rules, nil
+}
+
+func (r *Rule) init() error {
+	err := r.Series.Init()
+	if err != nil {
+		return err
+	}
+
+	if r.Operator != ABOVE && r.Operator != BELOW {
+		return fmt.Errorf("%q is not a valid operator", r.Operator)
+	}
+
+	r.buckets = 1
+	if len(r.For) != 0 {
+		d, err := time.ParseDuration(r.For)
+		if err != nil {
+			return fmt.Errorf("can't parse duration: %w", err)
+		}
+		if d%time.Minute != 0 || d <= 0 {
+			return fmt.Errorf("duration %q is not a positive multiple of a minute", r.For)
+		}
+		r.buckets = int(d / time.Minute)
+	}
+
+	return nil
+}
+
+func (r *Rule) breached(value int64) bool {
+	if r.Operator == ABOVE {
+		return value > r.Threshold
+	}
+
+	return value < r.Threshold
+}
@@ -0,0 +1,156 @@
+package anomaly
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
+	"k8s.io/klog/v2"
+	"math"
+	"time"
+)
+
+// minStddev keeps the z-score finite for series which have been constant so far.
+const minStddev = 1.0
+
+// Detector compares the closed buckets of the series to their baselines as the collector's watermark advances.
+// The watermarks of the collector all share a key, so a single partition holds the baselines of every series.
+type Detector struct {
+	series []Series
+	// alpha is the weight of the newest bucket in the baseline.
+	alpha  float64
+	zScore float64
+	// warmUp is the number of buckets a baseline needs before it's used for detection.
+	warmUp int
+	delay  time.Duration
+}
+
+func NewDetector(series []Series, alpha, zScore float64, warmUp int, delay time.Duration) *Detector {
+	return &Detector{
+		series: series,
+		alpha:  alpha,
+		zScore: zScore,
+		warmUp: warmUp,
+		delay:  delay,
+	}
+}
+
+func (d *Detector) Detect(ctx goka.Context, msg interface{}) {
+	var as api.AnomalyState
+
+	v := ctx.Value()
+	if v != nil {
+		as = v.(api.AnomalyState)
+	}
+
+	w, ok := msg.(api.Watermark)
+	if !ok {
+		klog.Errorf("received message's type is not of type Watermark")
+		return
+	}
+
+	if ctx.Key() != string(kafka.SinkGroup) {
+		return
+	}
+
+	if as.Baselines == nil {
+		as.Baselines = make(map[string][]api.Baseline)
+	}
+
+	for _, b := range as.Clock.Advance(w, d.delay) {
+		for i := range d.series {
+			s := &d.series[i]
+
+			ua := api.UserAggregates{}
+			v := ctx.Lookup(kafka.SinkTable, s.Key(b))
+			if v != nil {
+				ua = v.(api.UserAggregates)
+			}
+
+			slots := 1
+			slot := 0
+			if s.Seasonal {
+				slots = 24
+				slot = b.Hour()
+			}
+
+			baselines := as.Baselines[s.ID]
+			if len(baselines) != slots {
+				baselines = make([]api.Baseline, slots)
+			}
+
+			a := d.detect(&baselines[slot], s, b, s.Value(ua))
+			as.Baselines[s.ID] = baselines
+
+			if a != nil {
+				klog.V(2).InfoS("anomaly", "series", a.Series, "bucket", a.Bucket, "expected", a.Expected, "actual", a.Actual)
+				ctx.Emit(kafka.AnomalyTopic, util.GetBucketKey(b), *a)
+			}
+		}
+	}
+
+	ctx.SetValue(as)
+}
+
+// detect compares the value to the baseline, returning an anomaly if it deviates too much, and folds it into the baseline.
+func (d *Detector) detect(bl *api.Baseline, s *Series, bucket time.Time, value int64) *api.Anomaly {
+	x := float64(value)
+
+	var res *api.Anomaly
+	if bl.Samples >= d.warmUp {
+		threshold := d.zScore
+		if s.ZScore > 0 {
+			threshold = s.ZScore
+		}
+
+		z := (x - bl.Mean) / math.Max(math.Sqrt(bl.Variance), minStddev)
+		if math.Abs(z) > threshold {
+			res = &api.Anomaly{
+				Series:   s.ID,
+				Bucket:   bucket,
+				Expected: bl.Mean,
+				Actual:   value,
+				ZScore:   z,
+			}
+		}
+	}
+
+	if bl.Samples == 0 {
+		bl.Mean = x
+	} else {
+		diff := x - bl.Mean
+		incr := d.alpha * diff
+		bl.Mean += incr
+		bl.Variance = (1 - d.alpha) * (bl.Variance + diff*incr)
+	}
+	bl.Samples++
+
+	return res
+}
+
+// Collect keeps the anomalies of each bucket.
+func Collect(ctx goka.Context, msg interface{}) {
+	var as api.Anomalies
+
+	v := ctx.Value()
+	if v != nil {
+		as = v.(api.Anomalies)
+	}
+
+	a, ok := msg.(api.Anomaly)
+	if !ok {
+		klog.Errorf("received message's type is not of type Anomaly")
+		return
+	}
+
+	for i := range as.Anomalies {
+		if as.Anomalies[i].Series == a.Series {
+			as.Anomalies[i] = a
+			ctx.SetValue(as)
+			return
+		}
+	}
+	as.Anomalies = append(as.Anomalies, a)
+
+	ctx.SetValue(as)
+}
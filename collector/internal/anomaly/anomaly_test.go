@@ -0,0 +1,66 @@
+package anomaly
+
+import (
+	"github.com/rzetelskik/allezon-analytics/collector/internal/series"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetectorDetect(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+	s := &Series{Series: series.Series{ID: "buys"}}
+
+	ts := []struct {
+		name   string
+		warmUp int
+		values []int64
+		// expected holds the indices of the values flagged as anomalies
+		expected []int
+	}{
+		{
+			name:     "Steady series has no anomalies",
+			warmUp:   3,
+			values:   []int64{100, 102, 98, 101, 99, 100},
+			expected: []int{},
+		},
+		{
+			name:     "Spike is flagged",
+			warmUp:   3,
+			values:   []int64{100, 102, 98, 101, 99, 300, 100},
+			expected: []int{5},
+		},
+		{
+			name:     "Nothing is flagged during the warm-up",
+			warmUp:   10,
+			values:   []int64{100, 102, 300},
+			expected: []int{},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewDetector([]Series{*s}, 0.3, 3, test.warmUp, 0)
+			bl := api.Baseline{}
+
+			res := make([]int, 0)
+			for i, v := range test.values {
+				a := d.detect(&bl, s, base.Add(time.Duration(i)*time.Minute), v)
+				if a == nil {
+					continue
+				}
+
+				if a.Actual != v || a.Series != s.ID {
+					t.Errorf("anomaly doesn't match the bucket: %v", a)
+				}
+				res = append(res, i)
+			}
+
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and flagged buckets differ: %v != %v", test.expected, res)
+			}
+		})
+	}
+}
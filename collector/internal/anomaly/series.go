@@ -0,0 +1,54 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/collector/internal/series"
+	"os"
+)
+
+// Series is a series watched for anomalies.
+type Series struct {
+	series.Series
+	// ZScore overrides the detector's z-score threshold for the series.
+	ZScore float64 `json:"z_score,omitempty"`
+	// Seasonal keeps a separate baseline for each hour of the day.
+	Seasonal bool `json:"seasonal,omitempty"`
+}
+
+type seriesFile struct {
+	Series []Series `json:"series"`
+}
+
+// LoadSeries reads and validates the series stored in the file at path.
+func LoadSeries(path string) ([]Series, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read series file: %w", err)
+	}
+
+	var sf seriesFile
+	err = json.Unmarshal(data, &sf)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal series file: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	for i := range sf.Series {
+		err = sf.Series[i].Init()
+		if err != nil {
+			return nil, fmt.Errorf("invalid series %q: %w", sf.Series[i].ID, err)
+		}
+
+		if sf.Series[i].ZScore < 0 {
+			return nil, fmt.Errorf("invalid series %q: z-score can't be negative", sf.Series[i].ID)
+		}
+
+		if ids[sf.Series[i].ID] {
+			return nil, fmt.Errorf("duplicate series %q", sf.Series[i].ID)
+		}
+		ids[sf.Series[i].ID] = true
+	}
+
+	return sf.Series, nil
+}
@@ -0,0 +1,42 @@
+package cohort
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// Collect counts the members of each cohort and their activity in each period.
+func Collect(ctx goka.Context, msg interface{}) {
+	var ca api.CohortAggregates
+
+	v := ctx.Value()
+	if v != nil {
+		ca = v.(api.CohortAggregates)
+	}
+
+	ce, ok := msg.(api.CohortEvent)
+	if !ok {
+		klog.Errorf("received message's type is not of type CohortEvent")
+		return
+	}
+
+	if ce.Joined {
+		ca.Size += 1
+		ctx.SetValue(ca)
+		return
+	}
+
+	if ca.Active == nil {
+		ca.Active = make(map[string][]int64)
+	}
+
+	active := ca.Active[ce.Action.String()]
+	for len(active) <= ce.Period {
+		active = append(active, 0)
+	}
+	active[ce.Period] += 1
+	ca.Active[ce.Action.String()] = active
+
+	ctx.SetValue(ca)
+}
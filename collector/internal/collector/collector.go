@@ -3,10 +3,44 @@ package collector
 import (
 	"github.com/lovoo/goka"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/topk"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/watermark"
 	"k8s.io/klog/v2"
+	"time"
 )
 
-func Collect(ctx goka.Context, msg interface{}) {
+// Collector aggregates the user tags forwarded to its group's table.
+// Heavy hitter sketches are only kept if topKCapacity is positive.
+type Collector struct {
+	group        goka.Group
+	watermarks   *watermark.Tracker
+	latePolicy   watermark.LatePolicy
+	topKCapacity int
+}
+
+func NewCollector(group goka.Group, allowedLateness time.Duration, latePolicy watermark.LatePolicy, topKCapacity int) *Collector {
+	return &Collector{
+		group:        group,
+		watermarks:   watermark.NewTracker(allowedLateness),
+		latePolicy:   latePolicy,
+		topKCapacity: topKCapacity,
+	}
+}
+
+// addByCurrency adds the price in minor units of its original currency to the sums. Prices without a currency are only
+// summed in the base currency.
+func addByCurrency(sums *map[string]int64, currency string, price int64) {
+	if len(currency) == 0 {
+		return
+	}
+	if *sums == nil {
+		*sums = make(map[string]int64)
+	}
+	(*sums)[currency] += price
+}
+
+func (c *Collector) Collect(ctx goka.Context, msg interface{}) {
 	var ua api.UserAggregates
 
 	v := ctx.Value()
@@ -20,8 +54,131 @@ func Collect(ctx goka.Context, msg interface{}) {
 		return
 	}
 
-	ua.Count += 1
-	ua.SumPrice += int64(ut.Product.Price)
+	late := c.watermarks.Observe(ctx.Partition(), ut.Time)
+	defer c.watermarks.Emit(ctx, c.group)
+
+	// Heavy hitters are only kept in the unfiltered aggregates.
+	unfiltered := ctx.Key() == util.GetAggregateHash(ut.Time.Truncate(time.Minute), ut.Action)
+
+	if !c.update(&ua, ut, late, unfiltered) {
+		klog.V(3).InfoS("dropping late user tag", "cookie", ut.Cookie, "time", ut.Time, "watermark", c.watermarks.Watermark(ctx.Partition()))
+		return
+	}
 
 	ctx.SetValue(ua)
 }
+
+// update aggregates the user tag. It reports false if the user tag was dropped without being recorded.
+func (c *Collector) update(ua *api.UserAggregates, ut *api.UserTag, late bool, unfiltered bool) bool {
+	// Prices are converted to the base currency at ingest.
+	price, currency := ut.Product.NormalizedPrice(), ut.Product.Currency
+
+	switch {
+	case ut.Action == api.REFUND:
+		c.retract(ua, ut, price)
+	case ut.Dropped || (late && c.latePolicy == watermark.LatePolicyDrop):
+		if ut.Action != api.BUY {
+			return false
+		}
+		ua.DroppedPurchases = append(ua.DroppedPurchases, ut.PurchaseKey())
+	case late:
+		ua.LateCount += 1
+		ua.LateSumPrice += price
+		addByCurrency(&ua.LateSumPriceByCurrency, currency, int64(ut.Product.Price))
+		if ut.Action == api.BUY {
+			ua.LatePurchases = append(ua.LatePurchases, ut.PurchaseKey())
+		}
+	default:
+		ua.Count += 1
+		ua.SumPrice += price
+		addByCurrency(&ua.SumPriceByCurrency, currency, int64(ut.Product.Price))
+
+		if c.topKCapacity > 0 && unfiltered {
+			c.updateTopK(ua, ut, price)
+		}
+	}
+
+	return true
+}
+
+// retract subtracts the BUY refunded by ut from wherever it was counted. Heavy hitters are left as they are,
+// a sketch can't subtract weights soundly, so top-K results are approximate for refunded purchases.
+func (c *Collector) retract(ua *api.UserAggregates, ut *api.UserTag, price int64) {
+	key := ut.PurchaseKey()
+	if removeKey(&ua.DroppedPurchases, key) {
+		return
+	}
+
+	if removeKey(&ua.LatePurchases, key) {
+		ua.LateCount -= 1
+		ua.LateSumPrice -= price
+		addByCurrency(&ua.LateSumPriceByCurrency, ut.Product.Currency, -int64(ut.Product.Price))
+		return
+	}
+
+	ua.Count -= 1
+	ua.SumPrice -= price
+	addByCurrency(&ua.SumPriceByCurrency, ut.Product.Currency, -int64(ut.Product.Price))
+}
+
+// removeKey removes the purchase key from keys and reports whether it was there.
+func removeKey(keys *[]string, key string) bool {
+	for i, k := range *keys {
+		if k == key {
+			*keys = append((*keys)[:i], (*keys)[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Collector) updateTopK(ua *api.UserAggregates, ut *api.UserTag, price int64) {
+	if ua.TopK == nil {
+		ua.TopK = make(map[string]*topk.Sketch)
+	}
+
+	weights := map[api.TopMetric]int64{
+		api.TOP_COUNT:     1,
+		api.TOP_SUM_PRICE: price,
+	}
+
+	for _, d := range api.TopDimensions {
+		for m, w := range weights {
+			key := api.TopKey(d.Column, m)
+			s, ok := ua.TopK[key]
+			if !ok {
+				s = topk.NewSketch(c.topKCapacity)
+				ua.TopK[key] = s
+			}
+			s.Add(d.Extract(ut), w)
+		}
+	}
+}
+
+// MergeWatermarks folds the partition watermarks reported by a processor into a single table entry keyed by its group.
+func MergeWatermarks(ctx goka.Context, msg interface{}) {
+	var ws api.Watermarks
+
+	v := ctx.Value()
+	if v != nil {
+		ws = v.(api.Watermarks)
+	}
+
+	w, ok := msg.(api.Watermark)
+	if !ok {
+		klog.Errorf("received message's type is not of type Watermark")
+		return
+	}
+
+	if ws.Partitions == nil {
+		ws.Partitions = make(map[int32]time.Time)
+	}
+
+	if !w.Time.After(ws.Partitions[w.Partition]) {
+		return
+	}
+	ws.Partitions[w.Partition] = w.Time
+
+	ctx.SetValue(ws)
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/topk"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/watermark"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type step struct {
+	ut   api.UserTag
+	late bool
+}
+
+func TestUpdate(t *testing.T) {
+	purchaseTime := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	buy := api.UserTag{Time: purchaseTime, Cookie: "c", Action: api.BUY, Product: api.Product{ProductID: 1, BrandID: "Nike", Price: 100}}
+	dropped := buy
+	dropped.Dropped = true
+	refund := api.UserTag{Time: purchaseTime.Add(time.Hour), Cookie: "c", Action: api.REFUND, Product: buy.Product, PurchaseTime: &purchaseTime}
+
+	ts := []struct {
+		name       string
+		latePolicy watermark.LatePolicy
+		steps      []step
+		expected   api.UserAggregates
+	}{
+		{
+			name:       "REFUND of an on-time BUY is subtracted from the on-time aggregates",
+			latePolicy: watermark.LatePolicyCount,
+			steps:      []step{{ut: buy}, {ut: buy}, {ut: refund}},
+			expected:   api.UserAggregates{Count: 1, SumPrice: 100},
+		},
+		{
+			name:       "REFUND of a late BUY is subtracted from the late aggregates",
+			latePolicy: watermark.LatePolicyCount,
+			steps:      []step{{ut: buy}, {ut: buy, late: true}, {ut: refund}},
+			expected:   api.UserAggregates{Count: 1, SumPrice: 100, LatePurchases: []string{}},
+		},
+		{
+			name:       "REFUND of a BUY dropped by the collector isn't subtracted",
+			latePolicy: watermark.LatePolicyDrop,
+			steps:      []step{{ut: buy}, {ut: buy, late: true}, {ut: refund}},
+			expected:   api.UserAggregates{Count: 1, SumPrice: 100, DroppedPurchases: []string{}},
+		},
+		{
+			name:       "REFUND of a BUY dropped by the forwarder isn't subtracted",
+			latePolicy: watermark.LatePolicyCount,
+			steps:      []step{{ut: buy}, {ut: dropped}, {ut: refund}},
+			expected:   api.UserAggregates{Count: 1, SumPrice: 100, DroppedPurchases: []string{}},
+		},
+		{
+			name:       "Late REFUNDs aren't dropped",
+			latePolicy: watermark.LatePolicyDrop,
+			steps:      []step{{ut: buy}, {ut: refund, late: true}},
+			expected:   api.UserAggregates{},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewCollector("test", time.Minute, test.latePolicy, 0)
+
+			var ua api.UserAggregates
+			for _, s := range test.steps {
+				ut := s.ut
+				c.update(&ua, &ut, s.late, true)
+			}
+
+			if !reflect.DeepEqual(test.expected, ua) {
+				t.Errorf("expected and computed results differ: %+v, %+v", test.expected, ua)
+			}
+		})
+	}
+}
+
+func TestUpdateTopK(t *testing.T) {
+	purchaseTime := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	nike := api.UserTag{Time: purchaseTime, Cookie: "c", Action: api.BUY, Product: api.Product{ProductID: 1, BrandID: "Nike", Price: 100}}
+	adidas := api.UserTag{Time: purchaseTime, Cookie: "c", Action: api.BUY, Product: api.Product{ProductID: 2, BrandID: "Adidas", Price: 300}}
+	refund := api.UserTag{Time: purchaseTime.Add(time.Hour), Cookie: "c", Action: api.REFUND, Product: adidas.Product, PurchaseTime: &purchaseTime}
+
+	c := NewCollector("test", time.Minute, watermark.LatePolicyCount, 4)
+
+	var ua api.UserAggregates
+	for _, ut := range []api.UserTag{nike, adidas, refund} {
+		ut := ut
+		c.update(&ua, &ut, false, true)
+	}
+
+	// REFUNDs aren't retracted from heavy hitters.
+	expected := []topk.Counter{{Item: "Adidas", Count: 300}, {Item: "Nike", Count: 100}}
+	res := ua.TopK[api.TopKey(api.BrandDimension.Column, api.TOP_SUM_PRICE)].Top(4)
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected and computed results differ: %v, %v", expected, res)
+	}
+}
@@ -0,0 +1,40 @@
+package cooccurrence
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// Collector counts the products co-occurring with each product and action in hourly sketches covering the window.
+type Collector struct {
+	window   time.Duration
+	capacity int
+}
+
+func NewCollector(window time.Duration, capacity int) *Collector {
+	return &Collector{
+		window:   window,
+		capacity: capacity,
+	}
+}
+
+func (c *Collector) Collect(ctx goka.Context, msg interface{}) {
+	var co api.Cooccurrences
+
+	v := ctx.Value()
+	if v != nil {
+		co = v.(api.Cooccurrences)
+	}
+
+	ce, ok := msg.(api.CooccurrenceEvent)
+	if !ok {
+		klog.Errorf("received message's type is not of type CooccurrenceEvent")
+		return
+	}
+
+	co.Add(ce.ProductID, ce.Time, c.capacity, c.window)
+
+	ctx.SetValue(co)
+}
@@ -0,0 +1,44 @@
+package funnel
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+func Collect(ctx goka.Context, msg interface{}) {
+	var fa api.FunnelAggregates
+
+	v := ctx.Value()
+	if v != nil {
+		fa = v.(api.FunnelAggregates)
+	}
+
+	fe, ok := msg.(api.FunnelEvent)
+	if !ok {
+		klog.Errorf("received message's type is not of type FunnelEvent")
+		return
+	}
+
+	if !fe.Converted {
+		fa.Viewers += 1
+		if fe.MaxWindow > 0 && (fa.MaxWindow == 0 || fe.MaxWindow < fa.MaxWindow) {
+			fa.MaxWindow = fe.MaxWindow
+		}
+		ctx.SetValue(fa)
+		return
+	}
+
+	h := int(fe.Delay / time.Hour)
+	for len(fa.Converted) <= h {
+		fa.Converted = append(fa.Converted, 0)
+	}
+	if fe.Retracted {
+		fa.Converted[h] -= 1
+	} else {
+		fa.Converted[h] += 1
+	}
+
+	ctx.SetValue(fa)
+}
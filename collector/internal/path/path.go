@@ -0,0 +1,40 @@
+package path
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// Collector counts the paths leading to purchases of each target in hourly sketches covering the window.
+type Collector struct {
+	window   time.Duration
+	capacity int
+}
+
+func NewCollector(window time.Duration, capacity int) *Collector {
+	return &Collector{
+		window:   window,
+		capacity: capacity,
+	}
+}
+
+func (c *Collector) Collect(ctx goka.Context, msg interface{}) {
+	var p api.Paths
+
+	v := ctx.Value()
+	if v != nil {
+		p = v.(api.Paths)
+	}
+
+	pe, ok := msg.(api.PathEvent)
+	if !ok {
+		klog.Errorf("received message's type is not of type PathEvent")
+		return
+	}
+
+	p.Add(api.JoinPath(pe.Steps), pe.Time, c.capacity, c.window)
+
+	ctx.SetValue(p)
+}
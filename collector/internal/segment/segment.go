@@ -0,0 +1,33 @@
+package segment
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// Collect maintains the members of each segment keyed by api.SegmentMemberKey.
+// Members are kept until their cookies are found to have left, so the ones whose memberships ended have to be skipped.
+func Collect(ctx goka.Context, msg interface{}) {
+	se, ok := msg.(api.SegmentEvent)
+	if !ok {
+		klog.Errorf("received message's type is not of type SegmentEvent")
+		return
+	}
+
+	if !se.Entered {
+		ctx.Delete()
+		return
+	}
+
+	sm := api.SegmentMember{Since: se.Time, Until: se.Until}
+	// Redelivered events and the ones extending a membership mustn't move its start, unless the membership had ended.
+	if v := ctx.Value(); v != nil {
+		prev := v.(api.SegmentMember)
+		if prev.Active(se.Time) {
+			sm.Since = prev.Since
+		}
+	}
+
+	ctx.SetValue(sm)
+}
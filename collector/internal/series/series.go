@@ -0,0 +1,84 @@
+package series
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
+	"time"
+)
+
+// Series identifies an aggregate of the collector table over time, i.e. a GetAggregateHash series.
+type Series struct {
+	ID        string            `json:"id"`
+	Action    api.Action        `json:"action"`
+	Aggregate string            `json:"aggregate"`
+	Filters   map[string]string `json:"filters,omitempty"`
+
+	aggregate api.Aggregate
+	filters   []string
+}
+
+// Init validates the series and prepares its filters.
+func (s *Series) Init() error {
+	var err error
+
+	if len(s.ID) == 0 {
+		return errors.New("id is missing")
+	}
+
+	if s.Action == api.Action(0) {
+		return errors.New("action is missing")
+	}
+
+	s.aggregate, err = api.ParseAggregate(s.Aggregate)
+	if err != nil {
+		return err
+	}
+
+	// Filters have to follow the order of the registry to produce the same hash as the queries.
+	s.filters = make([]string, 0, len(s.Filters))
+	filtered := make([]api.Dimension, 0, len(s.Filters))
+	for _, d := range api.Dimensions {
+		v, ok := s.Filters[string(d.Column)]
+		if !ok {
+			continue
+		}
+		filtered = append(filtered, d)
+
+		if d.Validate != nil {
+			err = d.Validate(v)
+			if err != nil {
+				return fmt.Errorf("filter %q is invalid: %w", d.Column, err)
+			}
+		}
+		s.filters = append(s.filters, util.FilterValue(d.Column, v))
+	}
+	if len(filtered) != len(s.Filters) {
+		return errors.New("filters contain an unknown dimension")
+	}
+	if !api.Combinable(filtered) {
+		return errors.New("filters contain dimensions which can't be combined")
+	}
+
+	return nil
+}
+
+// Key returns the collector table key of the series in the bucket.
+func (s *Series) Key(bucket time.Time) string {
+	return util.GetAggregateHash(bucket, s.Action, s.filters...)
+}
+
+// Value returns the aggregate of the series.
+func (s *Series) Value(ua api.UserAggregates) int64 {
+	switch s.aggregate {
+	case api.AGGREGATE_SUM_PRICE:
+		return ua.SumPrice
+	case api.AGGREGATE_LATE_SUM_PRICE:
+		return ua.LateSumPrice
+	case api.AGGREGATE_LATE_COUNT:
+		return ua.LateCount
+	default:
+		return ua.Count
+	}
+}
@@ -0,0 +1,29 @@
+package session
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+func Collect(ctx goka.Context, msg interface{}) {
+	var sa api.SessionAggregates
+
+	v := ctx.Value()
+	if v != nil {
+		sa = v.(api.SessionAggregates)
+	}
+
+	se, ok := msg.(api.SessionEvent)
+	if !ok {
+		klog.Errorf("received message's type is not of type SessionEvent")
+		return
+	}
+
+	if se.Started {
+		sa.Count += 1
+	}
+	sa.TotalLength += se.Extension
+
+	ctx.SetValue(sa)
+}
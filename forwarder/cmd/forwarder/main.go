@@ -4,17 +4,59 @@ import (
 	"context"
 	"flag"
 	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/forwarder/internal/attribution"
+	"github.com/rzetelskik/allezon-analytics/forwarder/internal/cohort"
+	"github.com/rzetelskik/allezon-analytics/forwarder/internal/cooccurrence"
 	"github.com/rzetelskik/allezon-analytics/forwarder/internal/forwarder"
+	"github.com/rzetelskik/allezon-analytics/forwarder/internal/funnel"
+	"github.com/rzetelskik/allezon-analytics/forwarder/internal/path"
+	"github.com/rzetelskik/allezon-analytics/forwarder/internal/sessionizer"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/action"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/category"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/geo"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/reload"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/watermark"
 	"k8s.io/klog/v2"
 	"log"
 	"os"
 	"runtime"
+	"sync"
+	"time"
 )
 
 var (
 	bootstrap = []string{"kafka-cluster-kafka-bootstrap.kafka.svc.cluster.local:9092"}
+
+	actions = flag.String("actions", "", "path to the file listing the actions tracked besides VIEW, BUY and REFUND, user tags of other actions aren't aggregated")
+
+	allowedLateness = flag.Duration("allowed-lateness", 5*time.Minute, "how far behind the maximum observed event time a user tag can be before it's considered late")
+	latePolicy      = flag.String("late-policy", string(watermark.LatePolicyCount), "what to do with late user tags: 'drop' discards them, 'count' forwards them to be counted separately")
+	funnelMaxWindow = flag.Duration("funnel-max-window", 24*time.Hour, "longest VIEW-to-BUY conversion window the funnel can be queried for, it's published with the funnel aggregates")
+	funnelMaxMarks  = flag.Int("funnel-max-marks", 2000, "number of most recently viewed funnel marks kept per cookie")
+	sessionGap      = flag.Duration("session-gap", 30*time.Minute, "inactivity gap which ends a session")
+
+	cooccurrenceWindow      = flag.Duration("cooccurrence-window", 24*time.Hour, "how close in time two products a cookie interacted with must be to co-occur")
+	cooccurrenceMaxProducts = flag.Int("cooccurrence-max-products", 50, "number of most recent products kept per cookie for co-occurrences")
+
+	attributionLookback     = flag.Duration("attribution-lookback", 24*time.Hour, "how long before a BUY a VIEW of the product can be credited with it")
+	attributionMaxTouches   = flag.Int("attribution-max-touches", 200, "number of most recently viewed products kept per cookie for attribution")
+	attributionRefundWindow = flag.Duration("attribution-refund-window", 30*24*time.Hour, "how long after an attributed BUY its REFUND is credited to the same origin, it can't be shorter than the lookback")
+	attributionMaxPurchases = flag.Int("attribution-max-purchases", 200, "number of most recently attributed BUYs kept per cookie to credit their REFUNDs to the same origin")
+
+	pathWindow    = flag.Duration("path-window", 24*time.Hour, "how long before a BUY browsed categories make up the path leading to it")
+	pathMaxLength = flag.Int("path-max-length", 5, "number of steps of the longest paths counted, including the category of the bought product")
+
+	cohortPeriods = flag.Int("cohort-periods", 30, "number of daily periods cohort retention is tracked for")
+
+	geoReference      = flag.String("geo-reference", "", "path to the CSV file mapping countries to regions, markets and currencies, user tags aren't enriched if it's empty")
+	geoReloadInterval = flag.Duration("geo-reload-interval", time.Minute, "how often the geographic reference file is checked for modifications")
+
+	dimensionGroups = flag.String("dimension-groups", "", "comma-separated optional dimension groups aggregated besides the origin, brand, category and device, out of 'geo' and 'catalog'")
+
+	categoryHierarchy      = flag.String("category-hierarchy", "", "path to the JSON file listing the parents of categories, category aggregates aren't rolled up if it's empty")
+	categoryReloadInterval = flag.Duration("category-reload-interval", time.Minute, "how often the category hierarchy file is checked for modifications")
 )
 
 func main() {
@@ -31,22 +73,151 @@ func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	log.SetOutput(os.Stdout)
 
-	var group goka.Group = "forwarder"
-	g := goka.DefineGroup(group,
-		goka.Input(kafka.UserProfileTopic, new(api.UserTagCodec), forwarder.Forward),
-		goka.Output(kafka.AggregateTopic, new(api.UserTagCodec)),
-	)
+	if len(*actions) != 0 {
+		_, err = action.Load(*actions)
+		if err != nil {
+			klog.Fatalf("can't load actions: %v", err)
+		}
+	}
 
-	p, err := goka.NewProcessor(
-		bootstrap,
-		g,
-	)
+	lp, err := watermark.ParseLatePolicy(*latePolicy)
 	if err != nil {
-		klog.Fatalf("can't create new processor: %v", err)
+		klog.Fatalf("can't parse late policy: %v", err)
+	}
+
+	var geoRef *reload.File[geo.Reference]
+	if len(*geoReference) != 0 {
+		geoRef, err = reload.NewFile(*geoReference, geo.Parse)
+		if err != nil {
+			klog.Fatalf("can't load geographic reference: %v", err)
+		}
+	}
+
+	var categories *reload.File[category.Hierarchy]
+	if len(*categoryHierarchy) != 0 {
+		categories, err = reload.NewFile(*categoryHierarchy, category.Parse)
+		if err != nil {
+			klog.Fatalf("can't load category hierarchy: %v", err)
+		}
+	}
+
+	if *funnelMaxMarks <= 0 {
+		klog.Fatalf("funnel max marks has to be positive")
+	}
+
+	if *attributionMaxTouches <= 0 {
+		klog.Fatalf("attribution max touches has to be positive")
+	}
+
+	if *attributionRefundWindow < *attributionLookback {
+		klog.Fatalf("attribution refund window can't be shorter than the lookback")
 	}
 
-	err = p.Run(context.Background())
+	if *attributionMaxPurchases <= 0 {
+		klog.Fatalf("attribution max purchases has to be positive")
+	}
+
+	dimensions, err := api.EnabledDimensions(*dimensionGroups)
 	if err != nil {
-		klog.Fatalf("can't run processor: %v", err)
+		klog.Fatalf("can't parse dimension groups: %v", err)
+	}
+
+	enricher := forwarder.NewEnricher(geoRef, categories)
+	f := forwarder.NewForwarder(*allowedLateness, lp, enricher, dimensions)
+	fn := funnel.NewFunnel(*funnelMaxWindow, *funnelMaxMarks)
+	sz := sessionizer.NewSessionizer(*sessionGap)
+	co := cooccurrence.NewTracker(*cooccurrenceWindow, *cooccurrenceMaxProducts)
+	at := attribution.NewAttributor(*attributionLookback, *attributionMaxTouches, *attributionRefundWindow, *attributionMaxPurchases, enricher, dimensions)
+
+	if *cohortPeriods <= 0 || *cohortPeriods > api.CohortMaxPeriods {
+		klog.Fatalf("cohort periods have to be within [1, %d]", api.CohortMaxPeriods)
+	}
+	ch := cohort.NewTracker(*cohortPeriods)
+
+	if *pathMaxLength < 2 {
+		klog.Fatalf("path max length has to be at least 2")
+	}
+	pt := path.NewTracker(*pathWindow, *pathMaxLength)
+
+	groups := []*goka.GroupGraph{
+		goka.DefineGroup(kafka.ForwarderGroup,
+			goka.Input(kafka.UserProfileTopic, new(api.UserTagCodec), f.Forward),
+			goka.Lookup(kafka.CatalogTable, new(api.CatalogProductCodec)),
+			goka.Output(kafka.AggregateTopic, new(api.UserTagCodec)),
+			goka.Output(kafka.WatermarkTopic, new(api.WatermarkCodec)),
+		),
+		goka.DefineGroup(kafka.FunnelGroup,
+			goka.Input(kafka.UserProfileTopic, new(api.UserTagCodec), fn.Track),
+			goka.Output(kafka.FunnelTopic, new(api.FunnelEventCodec)),
+			goka.Persist(new(api.FunnelStateCodec)),
+		),
+		goka.DefineGroup(kafka.SessionGroup,
+			goka.Input(kafka.UserProfileTopic, new(api.UserTagCodec), sz.Track),
+			goka.Output(kafka.SessionTopic, new(api.SessionEventCodec)),
+			goka.Persist(new(api.SessionCodec)),
+		),
+		goka.DefineGroup(kafka.CooccurrenceGroup,
+			goka.Input(kafka.UserProfileTopic, new(api.UserTagCodec), co.Track),
+			goka.Output(kafka.CooccurrenceTopic, new(api.CooccurrenceEventCodec)),
+			goka.Persist(new(api.RecentProductsCodec)),
+		),
+		goka.DefineGroup(kafka.AttributionGroup,
+			goka.Input(kafka.UserProfileTopic, new(api.UserTagCodec), at.Attribute),
+			goka.Lookup(kafka.CatalogTable, new(api.CatalogProductCodec)),
+			goka.Output(kafka.AttributionTopic, new(api.UserTagCodec)),
+			goka.Persist(new(api.AttributionStateCodec)),
+		),
+		goka.DefineGroup(kafka.CohortGroup,
+			goka.Input(kafka.UserProfileTopic, new(api.UserTagCodec), ch.Track),
+			goka.Output(kafka.CohortTopic, new(api.CohortEventCodec)),
+			goka.Persist(new(api.CohortStateCodec)),
+		),
+		goka.DefineGroup(kafka.PathGroup,
+			goka.Input(kafka.UserProfileTopic, new(api.UserTagCodec), pt.Track),
+			goka.Output(kafka.PathTopic, new(api.PathEventCodec)),
+			goka.Persist(new(api.RecentCategoriesCodec)),
+		),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	if geoRef != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			geoRef.Run(ctx, *geoReloadInterval)
+		}()
+	}
+
+	if categories != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			categories.Run(ctx, *categoryReloadInterval)
+		}()
+	}
+
+	for _, g := range groups {
+		p, err := goka.NewProcessor(
+			bootstrap,
+			g,
+		)
+		if err != nil {
+			klog.Fatalf("can't create new processor: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := p.Run(ctx)
+			if err != nil {
+				klog.Fatalf("can't run processor %s: %v", p.Graph().Group(), err)
+			}
+		}()
 	}
 }
@@ -0,0 +1,183 @@
+package attribution
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/forwarder/internal/forwarder"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// Attributor credits each BUY to the origin of the cookie's most recent VIEW of the same product within the lookback window,
+// i.e. last-touch attribution. BUYs without such a VIEW keep their own origin.
+// REFUNDs are credited to the origin of their BUY, as long as it's among the maxPurchases most recently attributed BUYs
+// and it was made within the refund window of the most recent one. REFUNDs of BUYs attributed before that are retracted
+// from the aggregates of their own origin.
+// Attributed user tags are enriched like the forwarded ones, so that they're aggregated by the same dimensions.
+type Attributor struct {
+	lookback     time.Duration
+	maxTouches   int
+	refundWindow time.Duration
+	maxPurchases int
+	enricher     *forwarder.Enricher
+	dimensions   []api.Dimension
+}
+
+func NewAttributor(lookback time.Duration, maxTouches int, refundWindow time.Duration, maxPurchases int, enricher *forwarder.Enricher, dimensions []api.Dimension) *Attributor {
+	return &Attributor{
+		lookback:     lookback,
+		maxTouches:   maxTouches,
+		refundWindow: refundWindow,
+		maxPurchases: maxPurchases,
+		enricher:     enricher,
+		dimensions:   dimensions,
+	}
+}
+
+func (a *Attributor) Attribute(ctx goka.Context, msg interface{}) {
+	var as api.AttributionState
+
+	v := ctx.Value()
+	if v != nil {
+		as = v.(api.AttributionState)
+	}
+
+	ut, ok := msg.(*api.UserTag)
+	if !ok {
+		klog.Errorf("received message's type is not of type UserTag")
+		return
+	}
+
+	switch ut.Action {
+	case api.VIEW:
+		a.touch(&as, ut)
+		ctx.SetValue(as)
+
+	case api.BUY:
+		ancestors := a.enricher.Enrich(ctx, ut)
+		attributed := a.attribute(&as, ut)
+		if attributed.Origin != ut.Origin {
+			a.remember(&as, ut, attributed.Origin)
+			ctx.SetValue(as)
+		}
+		for _, hash := range forwarder.AggregateHashes(&attributed, a.dimensions, ancestors) {
+			ctx.Emit(kafka.AttributionTopic, hash, &attributed)
+		}
+
+	case api.REFUND:
+		ancestors := a.enricher.Enrich(ctx, ut)
+		attributed, ok := a.retract(&as, ut)
+		if ok {
+			ctx.SetValue(as)
+		}
+		// REFUNDs are sent to the aggregates their BUYs contributed to.
+		p := attributed.Purchase()
+		for _, hash := range forwarder.AggregateHashes(&p, a.dimensions, ancestors) {
+			ctx.Emit(kafka.AttributionTopic, hash, &attributed)
+		}
+	}
+}
+
+// touch records the VIEW as the most recent touch of its product and forgets the touches which fell out of the lookback window.
+func (a *Attributor) touch(as *api.AttributionState, ut *api.UserTag) {
+	latest := ut.Time
+	if len(as.Touches) > 0 && as.Touches[0].Time.After(latest) {
+		latest = as.Touches[0].Time
+	}
+
+	// known reports whether a more recent VIEW of the product has already been recorded.
+	known := false
+	touches := make([]api.Touch, 0, len(as.Touches)+1)
+	for _, t := range as.Touches {
+		if latest.Sub(t.Time) > a.lookback {
+			continue
+		}
+
+		if t.ProductID == ut.Product.ProductID {
+			if !t.Time.After(ut.Time) {
+				continue
+			}
+			known = true
+		}
+
+		touches = append(touches, t)
+	}
+
+	if known {
+		as.Touches = touches
+		return
+	}
+
+	t := api.Touch{
+		ProductID: ut.Product.ProductID,
+		Origin:    ut.Origin,
+		Time:      ut.Time,
+	}
+
+	i := 0
+	for i < len(touches) && touches[i].Time.After(t.Time) {
+		i++
+	}
+	touches = append(touches, api.Touch{})
+	copy(touches[i+1:], touches[i:])
+	touches[i] = t
+
+	if len(touches) > a.maxTouches {
+		touches = touches[:a.maxTouches]
+	}
+	as.Touches = touches
+}
+
+// attribute returns the BUY with its origin replaced by the origin of the touch it's attributed to.
+func (a *Attributor) attribute(as *api.AttributionState, ut *api.UserTag) api.UserTag {
+	attributed := *ut
+
+	for _, t := range as.Touches {
+		if t.ProductID != ut.Product.ProductID || t.Time.After(ut.Time) {
+			continue
+		}
+
+		if ut.Time.Sub(t.Time) <= a.lookback {
+			attributed.Origin = t.Origin
+		}
+		break
+	}
+
+	return attributed
+}
+
+// remember records the origin the BUY was attributed to, forgetting the BUYs made before the refund window and the least
+// recently attributed BUYs over the limit.
+func (a *Attributor) remember(as *api.AttributionState, ut *api.UserTag, origin string) {
+	purchases := make([]api.AttributedPurchase, 0, len(as.Purchases)+1)
+	purchases = append(purchases, api.AttributedPurchase{Key: ut.PurchaseKey(), Origin: origin, Time: ut.Time})
+	for _, p := range as.Purchases {
+		if !p.Time.IsZero() && ut.Time.Sub(p.Time) > a.refundWindow {
+			continue
+		}
+		purchases = append(purchases, p)
+	}
+
+	if len(purchases) > a.maxPurchases {
+		purchases = purchases[:a.maxPurchases]
+	}
+	as.Purchases = purchases
+}
+
+// retract returns the REFUND with its origin replaced by the origin its BUY was attributed to and forgets the BUY.
+// It reports false if the BUY isn't remembered, in which case the REFUND keeps its own origin.
+func (a *Attributor) retract(as *api.AttributionState, ut *api.UserTag) (api.UserTag, bool) {
+	attributed := *ut
+
+	key := ut.PurchaseKey()
+	for i, p := range as.Purchases {
+		if p.Key == key {
+			attributed.Origin = p.Origin
+			as.Purchases = append(as.Purchases[:i], as.Purchases[i+1:]...)
+			return attributed, true
+		}
+	}
+
+	return attributed, false
+}
@@ -0,0 +1,155 @@
+package attribution
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"testing"
+	"time"
+)
+
+func TestAttributorAttribute(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	tag := func(action api.Action, offset time.Duration, productID uint64, origin string) *api.UserTag {
+		return &api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Origin:  origin,
+			Product: api.Product{ProductID: productID},
+		}
+	}
+
+	ts := []struct {
+		name       string
+		maxTouches int
+		views      []*api.UserTag
+		buy        *api.UserTag
+		expected   string
+	}{
+		{
+			name:       "BUY is attributed to the most recent VIEW of the product",
+			maxTouches: 10,
+			views: []*api.UserTag{
+				tag(api.VIEW, 0, 1, "CAMPAIGN_A"),
+				tag(api.VIEW, time.Minute, 1, "CAMPAIGN_B"),
+				tag(api.VIEW, 2*time.Minute, 2, "CAMPAIGN_C"),
+			},
+			buy:      tag(api.BUY, 3*time.Minute, 1, "DIRECT"),
+			expected: "CAMPAIGN_B",
+		},
+		{
+			name:       "Out of order VIEW doesn't replace a more recent one",
+			maxTouches: 10,
+			views: []*api.UserTag{
+				tag(api.VIEW, time.Minute, 1, "CAMPAIGN_B"),
+				tag(api.VIEW, 0, 1, "CAMPAIGN_A"),
+			},
+			buy:      tag(api.BUY, 3*time.Minute, 1, "DIRECT"),
+			expected: "CAMPAIGN_B",
+		},
+		{
+			name:       "BUY without a VIEW of the product keeps its origin",
+			maxTouches: 10,
+			views: []*api.UserTag{
+				tag(api.VIEW, 0, 2, "CAMPAIGN_A"),
+			},
+			buy:      tag(api.BUY, time.Minute, 1, "DIRECT"),
+			expected: "DIRECT",
+		},
+		{
+			name:       "VIEW outside of the lookback window isn't credited",
+			maxTouches: 10,
+			views: []*api.UserTag{
+				tag(api.VIEW, 0, 1, "CAMPAIGN_A"),
+			},
+			buy:      tag(api.BUY, 2*time.Hour, 1, "DIRECT"),
+			expected: "DIRECT",
+		},
+		{
+			name:       "Only the most recent touches are kept",
+			maxTouches: 1,
+			views: []*api.UserTag{
+				tag(api.VIEW, 0, 1, "CAMPAIGN_A"),
+				tag(api.VIEW, time.Minute, 2, "CAMPAIGN_B"),
+			},
+			buy:      tag(api.BUY, 2*time.Minute, 1, "DIRECT"),
+			expected: "DIRECT",
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			a := NewAttributor(time.Hour, test.maxTouches, 24*time.Hour, 10, nil, api.Dimensions)
+			as := api.AttributionState{}
+			for _, ut := range test.views {
+				a.touch(&as, ut)
+			}
+
+			res := a.attribute(&as, test.buy)
+			if res.Origin != test.expected {
+				t.Errorf("expected and attributed origins differ: %s != %s", test.expected, res.Origin)
+			}
+			if test.buy.Origin != "DIRECT" {
+				t.Errorf("attribution modified the original user tag")
+			}
+		})
+	}
+}
+
+func TestAttributorRetract(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+	purchaseTime := base.Add(time.Minute)
+
+	buy := func(offset time.Duration, productID uint64) *api.UserTag {
+		return &api.UserTag{Time: purchaseTime.Add(offset), Cookie: "c", Action: api.BUY, Origin: "DIRECT", Product: api.Product{ProductID: productID}}
+	}
+	refund := &api.UserTag{Time: base.Add(time.Hour), Cookie: "c", Action: api.REFUND, Origin: "DIRECT", Product: api.Product{ProductID: 1}, PurchaseTime: &purchaseTime}
+
+	ts := []struct {
+		name         string
+		maxPurchases int
+		buys         []*api.UserTag
+		expected     string
+	}{
+		{
+			name:         "REFUND is credited to the origin its BUY was attributed to",
+			maxPurchases: 10,
+			buys:         []*api.UserTag{buy(0, 1), buy(0, 2)},
+			expected:     "CAMPAIGN_A",
+		},
+		{
+			name:         "REFUND of a forgotten BUY keeps its own origin",
+			maxPurchases: 1,
+			buys:         []*api.UserTag{buy(0, 1), buy(0, 2)},
+			expected:     "DIRECT",
+		},
+		{
+			name:         "REFUND of a BUY made before the refund window keeps its own origin",
+			maxPurchases: 10,
+			buys:         []*api.UserTag{buy(0, 1), buy(25*time.Hour, 2)},
+			expected:     "DIRECT",
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			a := NewAttributor(time.Hour, 10, 24*time.Hour, test.maxPurchases, nil, api.Dimensions)
+			as := api.AttributionState{}
+			for _, b := range test.buys {
+				a.remember(&as, b, "CAMPAIGN_A")
+			}
+
+			res, _ := a.retract(&as, refund)
+			if res.Origin != test.expected {
+				t.Errorf("expected and attributed origins differ: %s != %s", test.expected, res.Origin)
+			}
+
+			// REFUNDs are credited only once.
+			res, ok := a.retract(&as, refund)
+			if ok || res.Origin != "DIRECT" {
+				t.Errorf("REFUND was credited twice")
+			}
+		})
+	}
+}
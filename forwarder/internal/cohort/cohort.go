@@ -0,0 +1,96 @@
+package cohort
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"k8s.io/klog/v2"
+)
+
+// Tracker assigns cookies to cohorts and reports each cookie's activity once per action and period.
+// REFUNDs aren't retracted, a cookie which bought in a period stays active in it.
+type Tracker struct {
+	periods int
+}
+
+func NewTracker(periods int) *Tracker {
+	return &Tracker{
+		periods: periods,
+	}
+}
+
+type event struct {
+	key   string
+	value api.CohortEvent
+}
+
+func (tr *Tracker) Track(ctx goka.Context, msg interface{}) {
+	var cs api.CohortState
+
+	v := ctx.Value()
+	if v != nil {
+		cs = v.(api.CohortState)
+	}
+
+	ut, ok := msg.(*api.UserTag)
+	if !ok {
+		klog.Errorf("received message's type is not of type UserTag")
+		return
+	}
+
+	events := tr.update(&cs, ut)
+	if len(events) == 0 {
+		return
+	}
+
+	for _, e := range events {
+		ctx.Emit(kafka.CohortTopic, e.key, e.value)
+	}
+	ctx.SetValue(cs)
+}
+
+func (tr *Tracker) update(cs *api.CohortState, ut *api.UserTag) []event {
+	if ut.Action != api.VIEW && ut.Action != api.BUY {
+		return nil
+	}
+
+	if cs.Memberships == nil {
+		cs.Memberships = make(map[api.CohortKind]api.CohortMembership)
+	}
+
+	day := ut.Time.UTC().Truncate(api.CohortPeriod)
+
+	var events []event
+	for _, kind := range api.CohortKinds {
+		m, ok := cs.Memberships[kind]
+		if !ok {
+			switch kind {
+			case api.FIRST_SEEN:
+				m = api.CohortMembership{Cohort: day.Format("2006-01-02"), Start: day}
+			case api.FIRST_PURCHASE_BRAND:
+				if ut.Action != api.BUY {
+					continue
+				}
+				m = api.CohortMembership{Cohort: ut.Product.BrandID, Start: day}
+			}
+			m.Reported = make(map[string]uint64)
+
+			events = append(events, event{key: api.CohortKey(kind, m.Cohort), value: api.CohortEvent{Joined: true}})
+		}
+
+		period := int(day.Sub(m.Start) / api.CohortPeriod)
+		if period < 0 || period >= tr.periods {
+			cs.Memberships[kind] = m
+			continue
+		}
+
+		bit := uint64(1) << period
+		if m.Reported[ut.Action.String()]&bit == 0 {
+			m.Reported[ut.Action.String()] |= bit
+			events = append(events, event{key: api.CohortKey(kind, m.Cohort), value: api.CohortEvent{Action: ut.Action, Period: period}})
+		}
+		cs.Memberships[kind] = m
+	}
+
+	return events
+}
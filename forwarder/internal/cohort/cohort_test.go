@@ -0,0 +1,92 @@
+package cohort
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrackerUpdate(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	tag := func(action api.Action, offset time.Duration, brandID string) *api.UserTag {
+		return &api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Product: api.Product{BrandID: brandID},
+		}
+	}
+
+	firstSeen := api.CohortKey(api.FIRST_SEEN, "2022-03-22")
+	nike := api.CohortKey(api.FIRST_PURCHASE_BRAND, "Nike")
+
+	ts := []struct {
+		name     string
+		periods  int
+		tags     []*api.UserTag
+		expected []event
+	}{
+		{
+			name:    "Cookie joins the first seen cohort and is active once per period",
+			periods: 7,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, "Nike"),
+				tag(api.VIEW, time.Hour, "Nike"),
+				tag(api.VIEW, 24*time.Hour, "Nike"),
+			},
+			expected: []event{
+				{key: firstSeen, value: api.CohortEvent{Joined: true}},
+				{key: firstSeen, value: api.CohortEvent{Action: api.VIEW, Period: 0}},
+				{key: firstSeen, value: api.CohortEvent{Action: api.VIEW, Period: 1}},
+			},
+		},
+		{
+			name:    "First purchase assigns the brand cohort",
+			periods: 7,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, "Adidas"),
+				tag(api.BUY, 2*24*time.Hour, "Nike"),
+				tag(api.BUY, 3*24*time.Hour, "Adidas"),
+			},
+			expected: []event{
+				{key: firstSeen, value: api.CohortEvent{Joined: true}},
+				{key: firstSeen, value: api.CohortEvent{Action: api.VIEW, Period: 0}},
+				{key: firstSeen, value: api.CohortEvent{Action: api.BUY, Period: 2}},
+				{key: nike, value: api.CohortEvent{Joined: true}},
+				{key: nike, value: api.CohortEvent{Action: api.BUY, Period: 0}},
+				{key: firstSeen, value: api.CohortEvent{Action: api.BUY, Period: 3}},
+				{key: nike, value: api.CohortEvent{Action: api.BUY, Period: 1}},
+			},
+		},
+		{
+			name:    "Activity past the tracked periods isn't reported",
+			periods: 1,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, "Nike"),
+				tag(api.VIEW, 24*time.Hour, "Nike"),
+			},
+			expected: []event{
+				{key: firstSeen, value: api.CohortEvent{Joined: true}},
+				{key: firstSeen, value: api.CohortEvent{Action: api.VIEW, Period: 0}},
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			tr := NewTracker(test.periods)
+			cs := api.CohortState{}
+
+			res := make([]event, 0)
+			for _, ut := range test.tags {
+				res = append(res, tr.update(&cs, ut)...)
+			}
+
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %v != %v", test.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package cooccurrence
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"k8s.io/klog/v2"
+	"strconv"
+	"time"
+)
+
+// Tracker keeps the products each cookie viewed or bought within the window and reports every pair
+// of distinct products to the viewed ones. REFUNDs aren't retracted, having bought a product is an interaction with it.
+type Tracker struct {
+	window      time.Duration
+	maxProducts int
+}
+
+func NewTracker(window time.Duration, maxProducts int) *Tracker {
+	return &Tracker{
+		window:      window,
+		maxProducts: maxProducts,
+	}
+}
+
+type event struct {
+	key   string
+	value api.CooccurrenceEvent
+}
+
+func (tr *Tracker) Track(ctx goka.Context, msg interface{}) {
+	var rp api.RecentProducts
+
+	v := ctx.Value()
+	if v != nil {
+		rp = v.(api.RecentProducts)
+	}
+
+	ut, ok := msg.(*api.UserTag)
+	if !ok {
+		klog.Errorf("received message's type is not of type UserTag")
+		return
+	}
+
+	events, changed := tr.update(&rp, ut)
+	for _, e := range events {
+		ctx.Emit(kafka.CooccurrenceTopic, e.key, e.value)
+	}
+
+	if changed {
+		ctx.SetValue(rp)
+	}
+}
+
+// update records the product and action of ut and returns the pairs it forms with the other recent products.
+// Pairs are reported to the viewed product of each pair, keyed by the action taken on the other one.
+// A pair is only reported when the product enters the window, so repeated interactions aren't counted twice.
+func (tr *Tracker) update(rp *api.RecentProducts, ut *api.UserTag) ([]event, bool) {
+	if ut.Action != api.VIEW && ut.Action != api.BUY {
+		return nil, false
+	}
+
+	productID := strconv.FormatUint(ut.Product.ProductID, 10)
+
+	latest := ut.Time
+	if len(rp.Products) > 0 && rp.Products[0].Time.After(latest) {
+		latest = rp.Products[0].Time
+	}
+
+	var events []event
+	known := false
+	products := make([]api.RecentProduct, 0, len(rp.Products)+1)
+	for _, p := range rp.Products {
+		if latest.Sub(p.Time) >= tr.window {
+			continue
+		}
+
+		if p.ProductID == productID && p.Action == ut.Action {
+			known = true
+			if ut.Time.After(p.Time) {
+				continue
+			}
+		}
+
+		products = append(products, p)
+	}
+
+	if !known {
+		for _, p := range products {
+			if p.ProductID == productID || absDuration(p.Time.Sub(ut.Time)) >= tr.window {
+				continue
+			}
+
+			if ut.Action == api.VIEW {
+				events = append(events, event{key: api.CooccurrenceKey(p.Action, productID), value: api.CooccurrenceEvent{ProductID: p.ProductID, Time: ut.Time}})
+			}
+			if p.Action == api.VIEW {
+				events = append(events, event{key: api.CooccurrenceKey(ut.Action, p.ProductID), value: api.CooccurrenceEvent{ProductID: productID, Time: ut.Time}})
+			}
+		}
+	}
+
+	if !containsProduct(products, productID, ut.Action) {
+		products = insertRecent(products, api.RecentProduct{ProductID: productID, Action: ut.Action, Time: ut.Time})
+	}
+	if len(products) > tr.maxProducts {
+		products = products[:tr.maxProducts]
+	}
+	rp.Products = products
+
+	return events, true
+}
+
+func containsProduct(products []api.RecentProduct, productID string, action api.Action) bool {
+	for _, p := range products {
+		if p.ProductID == productID && p.Action == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+func insertRecent(products []api.RecentProduct, p api.RecentProduct) []api.RecentProduct {
+	i := 0
+	for i < len(products) && products[i].Time.After(p.Time) {
+		i++
+	}
+
+	products = append(products, api.RecentProduct{})
+	copy(products[i+1:], products[i:])
+	products[i] = p
+
+	return products
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
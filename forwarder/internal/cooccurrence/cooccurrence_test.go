@@ -0,0 +1,124 @@
+package cooccurrence
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrackerUpdate(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	tag := func(action api.Action, offset time.Duration, productID uint64) *api.UserTag {
+		return &api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Product: api.Product{ProductID: productID},
+		}
+	}
+
+	ts := []struct {
+		name        string
+		maxProducts int
+		tags        []*api.UserTag
+		// expected counts the emitted events per key and co-occurring product
+		expected map[string]map[string]int
+	}{
+		{
+			name:        "A single product forms no pairs",
+			maxProducts: 10,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, 1),
+				tag(api.BUY, time.Minute, 1),
+			},
+			expected: map[string]map[string]int{},
+		},
+		{
+			name:        "Viewed products are paired once with the products viewed and bought within the window",
+			maxProducts: 10,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, 1),
+				tag(api.VIEW, time.Minute, 2),
+				tag(api.BUY, 2*time.Minute, 2),
+				tag(api.VIEW, 3*time.Minute, 1),
+			},
+			expected: map[string]map[string]int{
+				api.CooccurrenceKey(api.VIEW, "1"): {"2": 1},
+				api.CooccurrenceKey(api.VIEW, "2"): {"1": 1},
+				api.CooccurrenceKey(api.BUY, "1"):  {"2": 1},
+			},
+		},
+		{
+			name:        "Bought products are only paired with the viewed ones",
+			maxProducts: 10,
+			tags: []*api.UserTag{
+				tag(api.BUY, 0, 1),
+				tag(api.BUY, time.Minute, 2),
+				tag(api.VIEW, 2*time.Minute, 3),
+			},
+			expected: map[string]map[string]int{
+				api.CooccurrenceKey(api.BUY, "3"): {"1": 1, "2": 1},
+			},
+		},
+		{
+			name:        "Products outside of the window aren't paired",
+			maxProducts: 10,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, 1),
+				tag(api.VIEW, 25*time.Hour, 2),
+			},
+			expected: map[string]map[string]int{},
+		},
+		{
+			name:        "Product re-entering the window is paired again",
+			maxProducts: 10,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, 1),
+				tag(api.VIEW, 25*time.Hour, 2),
+				tag(api.VIEW, 26*time.Hour, 1),
+			},
+			expected: map[string]map[string]int{
+				api.CooccurrenceKey(api.VIEW, "1"): {"2": 1},
+				api.CooccurrenceKey(api.VIEW, "2"): {"1": 1},
+			},
+		},
+		{
+			name:        "Only the most recent products are kept",
+			maxProducts: 1,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, 1),
+				tag(api.VIEW, time.Minute, 2),
+				tag(api.VIEW, 2*time.Minute, 3),
+			},
+			expected: map[string]map[string]int{
+				api.CooccurrenceKey(api.VIEW, "1"): {"2": 1},
+				api.CooccurrenceKey(api.VIEW, "2"): {"1": 1, "3": 1},
+				api.CooccurrenceKey(api.VIEW, "3"): {"2": 1},
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			tr := NewTracker(24*time.Hour, test.maxProducts)
+			rp := api.RecentProducts{}
+
+			res := make(map[string]map[string]int)
+			for _, ut := range test.tags {
+				events, _ := tr.update(&rp, ut)
+				for _, e := range events {
+					if res[e.key] == nil {
+						res[e.key] = make(map[string]int)
+					}
+					res[e.key][e.value.ProductID]++
+				}
+			}
+
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %v != %v", test.expected, res)
+			}
+		})
+	}
+}
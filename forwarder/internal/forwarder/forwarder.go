@@ -3,26 +3,110 @@ package forwarder
 import (
 	"github.com/lovoo/goka"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/category"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/geo"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
-	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/reload"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/watermark"
 	"k8s.io/klog/v2"
+	"strconv"
 	"time"
 )
 
-func Forward(ctx goka.Context, msg interface{}) {
+// Enricher sets the geographic and catalog properties of user tags before they're aggregated.
+type Enricher struct {
+	// geo is the reference user tags are enriched from. User tags aren't enriched if it's nil.
+	geo *reload.File[geo.Reference]
+	// categories is the hierarchy category aggregates are rolled up along. Categories aren't rolled up if it's nil.
+	categories *reload.File[category.Hierarchy]
+}
+
+func NewEnricher(geo *reload.File[geo.Reference], categories *reload.File[category.Hierarchy]) *Enricher {
+	return &Enricher{
+		geo:        geo,
+		categories: categories,
+	}
+}
+
+// Enrich sets the properties of ut and returns the ancestors of its category. The processor calling it has to look up
+// the catalog table.
+func (e *Enricher) Enrich(ctx goka.Context, ut *api.UserTag) []string {
+	ut.Geo = nil
+	if e.geo != nil {
+		ut.Geo = e.geo.Get().Lookup(ut.Country)
+	}
+
+	ut.Catalog = nil
+	if v := ctx.Lookup(kafka.CatalogTable, strconv.FormatUint(ut.Product.ProductID, 10)); v != nil {
+		cp := v.(api.CatalogProduct)
+		ut.Catalog = &cp
+	}
+
+	var ancestors []string
+	if e.categories != nil {
+		ancestors = e.categories.Get().Ancestors(ut.Product.CategoryID)
+	}
+
+	return ancestors
+}
+
+type Forwarder struct {
+	watermarks *watermark.Tracker
+	latePolicy watermark.LatePolicy
+	enricher   *Enricher
+	// dimensions are the ones aggregated by the deployment.
+	dimensions []api.Dimension
+}
+
+func NewForwarder(allowedLateness time.Duration, latePolicy watermark.LatePolicy, enricher *Enricher, dimensions []api.Dimension) *Forwarder {
+	return &Forwarder{
+		watermarks: watermark.NewTracker(allowedLateness),
+		latePolicy: latePolicy,
+		enricher:   enricher,
+		dimensions: dimensions,
+	}
+}
+
+func (fwd *Forwarder) Forward(ctx goka.Context, msg interface{}) {
 	ut, ok := msg.(*api.UserTag)
 	if !ok {
 		klog.Errorf("received message's type is not of type UserTag")
 		return
 	}
 
-	properties := []string{ut.Origin, ut.Product.BrandID, ut.Product.CategoryID}
-	filters := make([][]string, 0)
-	Backtrack([]string{}, properties, &filters)
+	// Actions missing from the forwarder's actions file can't be aggregated under their names.
+	if !ut.Action.Known() {
+		klog.V(2).InfoS("skipping user tag of an unknown action", "cookie", ut.Cookie, "time", ut.Time)
+		return
+	}
+
+	late := fwd.watermarks.Observe(ctx.Partition(), ut.Time)
+	defer fwd.watermarks.Emit(ctx, kafka.ForwarderGroup)
+
+	// Dropped BUYs are still forwarded, so that the collectors know not to retract their REFUNDs. REFUNDs are never late
+	// themselves, they retract from the aggregates of their BUYs.
+	ut.Dropped = false
+	if late && fwd.latePolicy == watermark.LatePolicyDrop {
+		switch ut.Action {
+		case api.BUY:
+			ut.Dropped = true
+		case api.REFUND:
+		default:
+			klog.V(3).InfoS("dropping late user tag", "cookie", ut.Cookie, "time", ut.Time, "watermark", fwd.watermarks.Watermark(ctx.Partition()))
+			return
+		}
+	}
+
+	ancestors := fwd.enricher.Enrich(ctx, ut)
+
+	// REFUNDs are sent to the aggregates their BUYs contributed to, i.e. to the ones of the purchase time's bucket.
+	contributed := ut
+	if ut.Action == api.REFUND {
+		p := ut.Purchase()
+		contributed = &p
+	}
 
-	bucket := ut.Time.Truncate(time.Minute)
-	for _, f := range filters {
-		hash := util.GetAggregateHash(bucket, ut.Action, f...)
+	for _, hash := range AggregateHashes(contributed, fwd.dimensions, ancestors) {
 		ctx.Emit(kafka.AggregateTopic, hash, ut)
 	}
 }
@@ -1,5 +1,91 @@
 package forwarder
 
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/category"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
+	"time"
+)
+
+// AggregateHashes returns the keys of every aggregate the user tag contributes to,
+// i.e. of each subset of its dimensions among ds which doesn't combine dimensions of the same group.
+// Derived dimensions the user tag hasn't been enriched with are left out, so that they don't alias each other.
+// Subsets which category.RolledUp are repeated with each of the category's ancestors, so that aggregates of the whole
+// subtrees are kept too.
+func AggregateHashes(ut *api.UserTag, ds []api.Dimension, ancestors []string) []string {
+	present := make([]api.Dimension, 0, len(ds))
+	properties := make([]string, 0, len(ds))
+	dimensions := make(map[string]api.Dimension, len(ds))
+	for _, d := range ds {
+		v := d.Extract(ut)
+		if d.Derived && len(v) == 0 {
+			continue
+		}
+
+		p := util.FilterValue(d.Column, v)
+		present = append(present, d)
+		properties = append(properties, p)
+		dimensions[p] = d
+	}
+
+	filters := make([][]string, 0)
+	combine(0, []string{}, make(map[string]bool), present, properties, &filters)
+
+	bucket := ut.Time.Truncate(time.Minute)
+	hashes := make([]string, 0, len(filters))
+	for _, f := range filters {
+		hashes = append(hashes, util.GetAggregateHash(bucket, ut.Action, f...))
+
+		columns := make([]api.AggregateColumn, 0, len(f))
+		for _, p := range f {
+			columns = append(columns, dimensions[p].Column)
+		}
+		if !category.RolledUp(columns) {
+			continue
+		}
+
+		for i, p := range f {
+			if dimensions[p].Column != api.CategoryDimension.Column {
+				continue
+			}
+			for _, a := range ancestors {
+				rolledUp := make([]string, len(f))
+				copy(rolledUp, f)
+				rolledUp[i] = util.FilterValue(api.CategoryDimension.Column, a)
+				hashes = append(hashes, util.GetAggregateHash(bucket, ut.Action, rolledUp...))
+			}
+		}
+	}
+
+	return hashes
+}
+
+// combine generates the subsets of properties which don't combine dimensions of the same group.
+// Properties are the filter values of the dimensions ds with the same indices.
+func combine(pos int, curr []string, groups map[string]bool, ds []api.Dimension, properties []string, res *[][]string) {
+	currDup := make([]string, len(curr))
+	copy(currDup, curr)
+	*res = append(*res, currDup)
+
+	for i := pos; i < len(ds); i++ {
+		g := ds[i].Group
+		if len(g) > 0 {
+			if groups[g] {
+				continue
+			}
+			groups[g] = true
+		}
+
+		curr = append(curr, properties[i])
+		combine(i+1, curr, groups, ds, properties, res)
+		curr = curr[:len(curr)-1]
+
+		if len(g) > 0 {
+			delete(groups, g)
+		}
+	}
+}
+
 func Backtrack(curr []string, ss []string, res *[][]string) {
 	backtrack(0, curr, ss, res)
 }
@@ -2,8 +2,11 @@ package forwarder
 
 import (
 	"github.com/google/go-cmp/cmp"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestBacktrack(t *testing.T) {
@@ -39,3 +42,204 @@ func TestBacktrack(t *testing.T) {
 		})
 	}
 }
+
+func TestAggregateHashes(t *testing.T) {
+	ut := &api.UserTag{
+		Time:    time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC),
+		Country: "PL",
+		Device:  api.PC,
+		Action:  api.VIEW,
+		Origin:  "X",
+		Product: api.Product{BrandID: "Nike", CategoryID: "SHOES"},
+		Geo:     &api.Geo{Region: "EUROPE", Market: "CEE", Currency: "PLN"},
+		Catalog: &api.CatalogProduct{CategoryPath: []string{"CLOTHING", "SHOES"}, Gender: "WOMEN", Margin: 0.3},
+	}
+
+	hashes := AggregateHashes(ut, api.Dimensions, nil)
+
+	// Origin, brand, category and device can be combined freely with either no geographic dimension or one of four,
+	// and with either no catalog dimension or one of three.
+	expected := 16 * 5 * 4
+	if len(hashes) != expected {
+		t.Errorf("expected %d hashes, got %d", expected, len(hashes))
+	}
+
+	seen := make(map[string]bool)
+	for _, h := range hashes {
+		seen[h] = true
+	}
+
+	bucket := ut.Time.Truncate(time.Minute)
+	for _, filters := range [][]string{
+		{},
+		{util.FilterValue(api.RegionDimension.Column, "EUROPE")},
+		{util.FilterValue(api.BrandDimension.Column, "Nike"), util.FilterValue(api.MarketDimension.Column, "CEE")},
+		{util.FilterValue(api.RegionDimension.Column, "EUROPE"), util.FilterValue(api.MarginBandDimension.Column, "MEDIUM")},
+	} {
+		if !seen[util.GetAggregateHash(bucket, api.VIEW, filters...)] {
+			t.Errorf("expected a hash for filters %v", filters)
+		}
+	}
+	if seen[util.GetAggregateHash(bucket, api.VIEW, util.FilterValue(api.CountryDimension.Column, "PL"), util.FilterValue(api.RegionDimension.Column, "EUROPE"))] {
+		t.Errorf("expected no hash combining country and region")
+	}
+}
+
+func TestAggregateHashesFanOut(t *testing.T) {
+	ut := &api.UserTag{
+		Time:    time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC),
+		Country: "PL",
+		Device:  api.PC,
+		Action:  api.VIEW,
+		Origin:  "X",
+		Product: api.Product{BrandID: "Nike", CategoryID: "WOMEN_SHOES"},
+		Geo:     &api.Geo{Region: "EUROPE", Market: "CEE", Currency: "PLN"},
+		Catalog: &api.CatalogProduct{CategoryPath: []string{"CLOTHING", "SHOES"}, Gender: "WOMEN", Margin: 0.3},
+	}
+	ancestors := []string{"WOMEN", "FASHION"}
+
+	// Each optional group multiplies the number of keys by the number of its dimensions plus one. Rolled up keys add
+	// the category alone and with the brand per ancestor, in each combination with the optional groups.
+	ts := []struct {
+		name     string
+		groups   string
+		expected int
+	}{
+		{
+			name:     "Optional groups disabled",
+			groups:   "",
+			expected: 16 + 2*2,
+		},
+		{
+			name:     "Geo group enabled",
+			groups:   "geo",
+			expected: 16*5 + 2*2,
+		},
+		{
+			name:     "Catalog group enabled",
+			groups:   "catalog",
+			expected: 16*4 + 2*2,
+		},
+		{
+			name:     "Both groups enabled",
+			groups:   "geo,catalog",
+			expected: 16*5*4 + 2*2,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			ds, err := api.EnabledDimensions(test.groups)
+			if err != nil {
+				t.Fatalf("can't enable dimension groups: %v", err)
+			}
+
+			hashes := AggregateHashes(ut, ds, ancestors)
+			if len(hashes) != test.expected {
+				t.Errorf("expected %d hashes, got %d", test.expected, len(hashes))
+			}
+		})
+	}
+}
+
+func TestAggregateHashesWithoutEnrichment(t *testing.T) {
+	ut := &api.UserTag{
+		Time:    time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC),
+		Country: "PL",
+		Device:  api.PC,
+		Action:  api.VIEW,
+		Origin:  "X",
+		Product: api.Product{BrandID: "Nike", CategoryID: "SHOES"},
+	}
+
+	hashes := AggregateHashes(ut, api.Dimensions, nil)
+
+	// Only origin, brand, category, device and country can be combined, derived dimensions without values are left out.
+	expected := 32
+	if len(hashes) != expected {
+		t.Errorf("expected %d hashes, got %d", expected, len(hashes))
+	}
+
+	bucket := ut.Time.Truncate(time.Minute)
+	for _, h := range hashes {
+		for _, d := range []api.Dimension{api.RegionDimension, api.DepartmentDimension} {
+			if h == util.GetAggregateHash(bucket, api.VIEW, util.FilterValue(d.Column, "")) {
+				t.Errorf("expected no hash for an empty %s", d.Column)
+			}
+		}
+	}
+}
+
+func TestAggregateHashesPartialCatalog(t *testing.T) {
+	ut := &api.UserTag{
+		Time:    time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC),
+		Country: "PL",
+		Device:  api.PC,
+		Action:  api.VIEW,
+		Origin:  "X",
+		Product: api.Product{BrandID: "Nike", CategoryID: "SHOES"},
+		Catalog: &api.CatalogProduct{Margin: 0.1},
+	}
+
+	hashes := AggregateHashes(ut, api.Dimensions, nil)
+
+	// The catalog entry has neither a category path nor a gender, so only the margin band is added.
+	expected := 32 * 2
+	if len(hashes) != expected {
+		t.Errorf("expected %d hashes, got %d", expected, len(hashes))
+	}
+
+	seen := make(map[string]bool)
+	for _, h := range hashes {
+		seen[h] = true
+	}
+
+	bucket := ut.Time.Truncate(time.Minute)
+	if !seen[util.GetAggregateHash(bucket, api.VIEW, util.FilterValue(api.MarginBandDimension.Column, "LOW"))] {
+		t.Errorf("expected a hash for the margin band")
+	}
+	for _, d := range []api.Dimension{api.DepartmentDimension, api.GenderDimension} {
+		if seen[util.GetAggregateHash(bucket, api.VIEW, util.FilterValue(d.Column, ""))] {
+			t.Errorf("expected no hash for an empty %s", d.Column)
+		}
+	}
+}
+
+func TestAggregateHashesRollUp(t *testing.T) {
+	ut := &api.UserTag{
+		Time:    time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC),
+		Country: "PL",
+		Device:  api.PC,
+		Action:  api.VIEW,
+		Origin:  "X",
+		Product: api.Product{BrandID: "Nike", CategoryID: "WOMEN_SHOES"},
+	}
+
+	hashes := AggregateHashes(ut, api.Dimensions, []string{"WOMEN", "FASHION"})
+
+	// Only the hashes of the category alone and of the category with the brand are repeated for both ancestors.
+	expected := 32 + 2*2
+	if len(hashes) != expected {
+		t.Errorf("expected %d hashes, got %d", expected, len(hashes))
+	}
+
+	seen := make(map[string]bool)
+	for _, h := range hashes {
+		seen[h] = true
+	}
+
+	bucket := ut.Time.Truncate(time.Minute)
+	for _, filters := range [][]string{
+		{util.FilterValue(api.CategoryDimension.Column, "WOMEN_SHOES")},
+		{util.FilterValue(api.CategoryDimension.Column, "WOMEN")},
+		{util.FilterValue(api.BrandDimension.Column, "Nike"), util.FilterValue(api.CategoryDimension.Column, "FASHION")},
+	} {
+		if !seen[util.GetAggregateHash(bucket, api.VIEW, filters...)] {
+			t.Errorf("expected a hash for filters %v", filters)
+		}
+	}
+	if seen[util.GetAggregateHash(bucket, api.VIEW, util.FilterValue(api.CategoryDimension.Column, "WOMEN"), util.FilterValue(api.DeviceDimension.Column, "PC"))] {
+		t.Errorf("expected no rolled up hash combining the category and the device")
+	}
+}
@@ -0,0 +1,217 @@
+package funnel
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/forwarder/internal/forwarder"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
+	"k8s.io/klog/v2"
+	"sort"
+	"time"
+)
+
+type event struct {
+	key   string
+	value api.FunnelEvent
+}
+
+// Funnel tracks, per cookie, which funnels it entered by viewing products and emits an event when it enters or converts in one.
+type Funnel struct {
+	maxWindow time.Duration
+	maxMarks  int
+}
+
+func NewFunnel(maxWindow time.Duration, maxMarks int) *Funnel {
+	return &Funnel{
+		maxWindow: maxWindow,
+		maxMarks:  maxMarks,
+	}
+}
+
+func (f *Funnel) Track(ctx goka.Context, msg interface{}) {
+	var fs api.FunnelState
+
+	v := ctx.Value()
+	if v != nil {
+		fs = v.(api.FunnelState)
+	}
+
+	ut, ok := msg.(*api.UserTag)
+	if !ok {
+		klog.Errorf("received message's type is not of type UserTag")
+		return
+	}
+
+	events, ok := f.update(&fs, ut)
+	if !ok {
+		return
+	}
+
+	for _, e := range events {
+		ctx.Emit(kafka.FunnelTopic, e.key, e.value)
+	}
+
+	ctx.SetValue(fs)
+}
+
+func (f *Funnel) update(fs *api.FunnelState, ut *api.UserTag) ([]event, bool) {
+	var events []event
+
+	switch ut.Action {
+	case api.VIEW:
+		events = f.view(fs, ut)
+	case api.BUY:
+		events = f.buy(fs, ut)
+	case api.REFUND:
+		events = f.refund(fs, ut)
+	default:
+		return nil, false
+	}
+
+	horizon := ut.Time.Add(-f.maxWindow)
+	for k, m := range fs.Marks {
+		if !m.ViewedAt.After(horizon) {
+			delete(fs.Marks, k)
+		}
+	}
+	evictMarks(fs, f.maxMarks)
+
+	return events, true
+}
+
+func properties(ut *api.UserTag) []string {
+	res := make([]string, 0, len(api.FunnelDimensions))
+	for _, d := range api.FunnelDimensions {
+		res = append(res, util.FilterValue(d.Column, d.Extract(ut)))
+	}
+
+	return res
+}
+
+// evictMarks drops the marks viewed least recently until at most limit of them are left.
+func evictMarks(fs *api.FunnelState, limit int) {
+	if len(fs.Marks) <= limit {
+		return
+	}
+
+	keys := make([]string, 0, len(fs.Marks))
+	for k := range fs.Marks {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := fs.Marks[keys[i]], fs.Marks[keys[j]]
+		if !a.ViewedAt.Equal(b.ViewedAt) {
+			return a.ViewedAt.Before(b.ViewedAt)
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range keys[:len(keys)-limit] {
+		delete(fs.Marks, k)
+	}
+}
+
+func (f *Funnel) view(fs *api.FunnelState, ut *api.UserTag) []event {
+	bucket := ut.Time.Truncate(time.Minute)
+
+	subsets := make([][]string, 0)
+	forwarder.Backtrack([]string{}, properties(ut), &subsets)
+
+	if fs.Marks == nil {
+		fs.Marks = make(map[string]api.FunnelMark)
+	}
+
+	events := make([]event, 0)
+	for _, filters := range subsets {
+		key := util.GetAggregateHash(bucket, api.VIEW, filters...)
+		if m, ok := fs.Marks[key]; ok {
+			if ut.Time.Before(m.ViewedAt) {
+				m.ViewedAt = ut.Time
+				fs.Marks[key] = m
+			}
+			continue
+		}
+
+		fs.Marks[key] = api.FunnelMark{
+			Bucket:   bucket,
+			Filters:  filters,
+			ViewedAt: ut.Time,
+		}
+		events = append(events, event{
+			key: key,
+			value: api.FunnelEvent{
+				MaxWindow: f.maxWindow,
+			},
+		})
+	}
+
+	return events
+}
+
+func (f *Funnel) buy(fs *api.FunnelState, ut *api.UserTag) []event {
+	bought := make(map[string]bool)
+	for _, p := range properties(ut) {
+		bought[p] = true
+	}
+
+	events := make([]event, 0)
+	for k, m := range fs.Marks {
+		delay := ut.Time.Sub(m.ViewedAt)
+		if m.Converted || delay < 0 || delay >= f.maxWindow {
+			continue
+		}
+
+		matches := true
+		for _, p := range m.Filters {
+			if !bought[p] {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		m.Converted = true
+		m.ConvertedBy = ut.PurchaseKey()
+		fs.Marks[k] = m
+		events = append(events, event{
+			key: k,
+			value: api.FunnelEvent{
+				Converted: true,
+				Delay:     delay,
+			},
+		})
+	}
+
+	return events
+}
+
+// refund retracts the conversions of the refunded BUY. Conversions of marks which fell out of the window by the time
+// of the REFUND can't be retracted anymore.
+func (f *Funnel) refund(fs *api.FunnelState, ut *api.UserTag) []event {
+	key := ut.PurchaseKey()
+	p := ut.Purchase()
+
+	events := make([]event, 0)
+	for k, m := range fs.Marks {
+		if !m.Converted || m.ConvertedBy != key {
+			continue
+		}
+
+		m.Converted = false
+		m.ConvertedBy = ""
+		fs.Marks[k] = m
+		events = append(events, event{
+			key: k,
+			value: api.FunnelEvent{
+				Converted: true,
+				Delay:     p.Time.Sub(m.ViewedAt),
+				Retracted: true,
+			},
+		})
+	}
+
+	return events
+}
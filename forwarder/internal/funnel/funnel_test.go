@@ -0,0 +1,150 @@
+package funnel
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
+	"testing"
+	"time"
+)
+
+func TestFunnelUpdate(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 15, 0, 0, time.UTC)
+
+	tag := func(action api.Action, t time.Time, productID uint64, brandID string) *api.UserTag {
+		return &api.UserTag{
+			Time:   t,
+			Action: action,
+			Product: api.Product{
+				ProductID:  productID,
+				BrandID:    brandID,
+				CategoryID: "WOMEN_SHOES",
+			},
+		}
+	}
+
+	purchaseTime := base.Add(time.Minute)
+	refund := tag(api.REFUND, base.Add(30*time.Minute), 1, "Nike")
+	refund.PurchaseTime = &purchaseTime
+
+	brandFilter := util.FilterValue(api.BrandDimension.Column, "Nike")
+	brandKey := util.GetAggregateHash(base, api.VIEW, brandFilter)
+	productKey := util.GetAggregateHash(base, api.VIEW, util.FilterValue(api.ProductDimension.Column, "1"))
+
+	ts := []struct {
+		name      string
+		maxWindow time.Duration
+		maxMarks  int
+		tags      []*api.UserTag
+		// expectedViewers and expectedConverted count the emitted events per key
+		expectedViewers   map[string]int
+		expectedConverted map[string]int
+	}{
+		{
+			name:      "Cookie enters each funnel once per bucket",
+			maxWindow: time.Hour,
+			maxMarks:  100,
+			tags: []*api.UserTag{
+				tag(api.VIEW, base, 1, "Nike"),
+				tag(api.VIEW, base.Add(time.Second), 2, "Nike"),
+			},
+			expectedViewers:   map[string]int{brandKey: 1, productKey: 1},
+			expectedConverted: map[string]int{},
+		},
+		{
+			name:      "Buying a product converts the funnels of the matching view",
+			maxWindow: time.Hour,
+			maxMarks:  100,
+			tags: []*api.UserTag{
+				tag(api.VIEW, base, 1, "Nike"),
+				tag(api.BUY, base.Add(time.Minute), 1, "Nike"),
+				tag(api.BUY, base.Add(2*time.Minute), 1, "Nike"),
+			},
+			expectedViewers:   map[string]int{brandKey: 1, productKey: 1},
+			expectedConverted: map[string]int{brandKey: 1, productKey: 1},
+		},
+		{
+			name:      "Buying another product of the brand converts only the brand funnel",
+			maxWindow: time.Hour,
+			maxMarks:  100,
+			tags: []*api.UserTag{
+				tag(api.VIEW, base, 1, "Nike"),
+				tag(api.BUY, base.Add(time.Minute), 2, "Nike"),
+			},
+			expectedViewers:   map[string]int{brandKey: 1, productKey: 1},
+			expectedConverted: map[string]int{brandKey: 1},
+		},
+		{
+			name:      "Refunding the BUY retracts its conversions",
+			maxWindow: time.Hour,
+			maxMarks:  100,
+			tags: []*api.UserTag{
+				tag(api.VIEW, base, 1, "Nike"),
+				tag(api.BUY, purchaseTime, 1, "Nike"),
+				refund,
+				refund,
+			},
+			expectedViewers:   map[string]int{brandKey: 1, productKey: 1},
+			expectedConverted: map[string]int{brandKey: 0, productKey: 0},
+		},
+		{
+			name:      "Buying after the window doesn't convert",
+			maxWindow: time.Hour,
+			maxMarks:  100,
+			tags: []*api.UserTag{
+				tag(api.VIEW, base, 1, "Nike"),
+				tag(api.BUY, base.Add(2*time.Hour), 1, "Nike"),
+			},
+			expectedViewers:   map[string]int{brandKey: 1, productKey: 1},
+			expectedConverted: map[string]int{},
+		},
+		{
+			name:      "Marks viewed least recently are dropped over the limit",
+			maxWindow: time.Hour,
+			maxMarks:  8,
+			tags: []*api.UserTag{
+				tag(api.VIEW, base, 1, "Nike"),
+				tag(api.VIEW, base.Add(time.Minute), 2, "Nike"),
+				tag(api.BUY, base.Add(2*time.Minute), 1, "Nike"),
+			},
+			expectedViewers:   map[string]int{brandKey: 1, productKey: 1},
+			expectedConverted: map[string]int{brandKey: 0, productKey: 0},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			f := NewFunnel(test.maxWindow, test.maxMarks)
+			fs := api.FunnelState{}
+
+			viewers := make(map[string]int)
+			converted := make(map[string]int)
+			for _, ut := range test.tags {
+				events, _ := f.update(&fs, ut)
+				for _, e := range events {
+					if e.value.Retracted {
+						converted[e.key]--
+					} else if e.value.Converted {
+						converted[e.key]++
+					} else {
+						viewers[e.key]++
+					}
+				}
+			}
+
+			for k, n := range test.expectedViewers {
+				if viewers[k] != n {
+					t.Errorf("expected %d viewer events for %s, got %d", n, k, viewers[k])
+				}
+			}
+			for k, n := range test.expectedConverted {
+				if converted[k] != n {
+					t.Errorf("expected %d converted events for %s, got %d", n, k, converted[k])
+				}
+			}
+			if len(test.expectedConverted) == 0 && len(converted) != 0 {
+				t.Errorf("expected no converted events, got %v", converted)
+			}
+		})
+	}
+}
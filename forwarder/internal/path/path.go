@@ -0,0 +1,125 @@
+package path
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// Tracker keeps the categories each cookie browsed within the window and reports the paths of every length
+// between 2 and maxLength leading to each BUY to both its category and its brand. REFUNDs aren't retracted,
+// paths are counted in sketches which can't subtract them soundly.
+type Tracker struct {
+	window    time.Duration
+	maxLength int
+}
+
+func NewTracker(window time.Duration, maxLength int) *Tracker {
+	return &Tracker{
+		window:    window,
+		maxLength: maxLength,
+	}
+}
+
+type event struct {
+	key   string
+	value api.PathEvent
+}
+
+func (tr *Tracker) Track(ctx goka.Context, msg interface{}) {
+	var rc api.RecentCategories
+
+	v := ctx.Value()
+	if v != nil {
+		rc = v.(api.RecentCategories)
+	}
+
+	ut, ok := msg.(*api.UserTag)
+	if !ok {
+		klog.Errorf("received message's type is not of type UserTag")
+		return
+	}
+
+	events, changed := tr.update(&rc, ut)
+	for _, e := range events {
+		ctx.Emit(kafka.PathTopic, e.key, e.value)
+	}
+
+	if changed {
+		ctx.SetValue(rc)
+	}
+}
+
+// update records the category of a VIEW or returns the paths leading to a BUY.
+func (tr *Tracker) update(rc *api.RecentCategories, ut *api.UserTag) ([]event, bool) {
+	switch ut.Action {
+	case api.VIEW:
+		tr.view(rc, ut)
+		return nil, true
+
+	case api.BUY:
+		steps := make([]string, 0, tr.maxLength)
+		for _, s := range rc.Steps {
+			if s.Time.After(ut.Time) || ut.Time.Sub(s.Time) > tr.window {
+				continue
+			}
+			steps = appendStep(steps, s.CategoryID)
+		}
+		steps = appendStep(steps, ut.Product.CategoryID)
+
+		var events []event
+		for n := 2; n <= tr.maxLength && n <= len(steps); n++ {
+			pe := api.PathEvent{Steps: steps[len(steps)-n:], Time: ut.Time}
+			events = append(events,
+				event{key: api.PathKey(api.CategoryDimension.Column, ut.Product.CategoryID, n), value: pe},
+				event{key: api.PathKey(api.BrandDimension.Column, ut.Product.BrandID, n), value: pe},
+			)
+		}
+
+		return events, false
+
+	default:
+		return nil, false
+	}
+}
+
+// view inserts the category of ut into the steps sorted by time, merging it with a neighbouring step of the same category.
+func (tr *Tracker) view(rc *api.RecentCategories, ut *api.UserTag) {
+	i := len(rc.Steps)
+	for i > 0 && rc.Steps[i-1].Time.After(ut.Time) {
+		i--
+	}
+
+	switch {
+	case i > 0 && rc.Steps[i-1].CategoryID == ut.Product.CategoryID:
+		// The step is extended to its most recent view.
+		rc.Steps[i-1].Time = ut.Time
+	case i < len(rc.Steps) && rc.Steps[i].CategoryID == ut.Product.CategoryID:
+	default:
+		rc.Steps = append(rc.Steps, api.PathStep{})
+		copy(rc.Steps[i+1:], rc.Steps[i:])
+		rc.Steps[i] = api.PathStep{CategoryID: ut.Product.CategoryID, Time: ut.Time}
+	}
+
+	latest := rc.Steps[len(rc.Steps)-1].Time
+	first := 0
+	for first < len(rc.Steps) && latest.Sub(rc.Steps[first].Time) > tr.window {
+		first++
+	}
+	// Paths end with the category of the bought product, so they need one step less of the browsed ones.
+	if len(rc.Steps)-first > tr.maxLength-1 {
+		first = len(rc.Steps) - (tr.maxLength - 1)
+	}
+	rc.Steps = rc.Steps[first:]
+}
+
+// appendStep appends the category unless it's the same as the last step.
+func appendStep(steps []string, categoryID string) []string {
+	if len(steps) > 0 && steps[len(steps)-1] == categoryID {
+		return steps
+	}
+
+	return append(steps, categoryID)
+}
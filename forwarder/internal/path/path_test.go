@@ -0,0 +1,100 @@
+package path
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrackerUpdate(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	tag := func(action api.Action, offset time.Duration, category string) *api.UserTag {
+		return &api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Product: api.Product{BrandID: "Nike", CategoryID: category},
+		}
+	}
+
+	ts := []struct {
+		name      string
+		maxLength int
+		tags      []*api.UserTag
+		// expected holds the emitted paths keyed by the category targets
+		expected map[string][]string
+	}{
+		{
+			name:      "A BUY without views forms no paths",
+			maxLength: 3,
+			tags: []*api.UserTag{
+				tag(api.BUY, 0, "SHOES"),
+			},
+			expected: map[string][]string{},
+		},
+		{
+			name:      "Paths of every length end with the bought category",
+			maxLength: 3,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, "HATS"),
+				tag(api.VIEW, time.Minute, "BAGS"),
+				tag(api.VIEW, 2*time.Minute, "BAGS"),
+				tag(api.BUY, 3*time.Minute, "SHOES"),
+			},
+			expected: map[string][]string{
+				api.PathKey(api.CategoryDimension.Column, "SHOES", 2): {"BAGS", "SHOES"},
+				api.PathKey(api.CategoryDimension.Column, "SHOES", 3): {"HATS", "BAGS", "SHOES"},
+			},
+		},
+		{
+			name:      "Viewing the bought category doesn't add a step",
+			maxLength: 3,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, "HATS"),
+				tag(api.VIEW, time.Minute, "SHOES"),
+				tag(api.BUY, 2*time.Minute, "SHOES"),
+			},
+			expected: map[string][]string{
+				api.PathKey(api.CategoryDimension.Column, "SHOES", 2): {"HATS", "SHOES"},
+			},
+		},
+		{
+			name:      "Late views are inserted in time order and views outside of the window are left out",
+			maxLength: 4,
+			tags: []*api.UserTag{
+				tag(api.VIEW, 0, "COATS"),
+				tag(api.VIEW, 26*time.Hour, "BAGS"),
+				tag(api.VIEW, 25*time.Hour, "HATS"),
+				tag(api.BUY, 27*time.Hour, "SHOES"),
+			},
+			expected: map[string][]string{
+				api.PathKey(api.CategoryDimension.Column, "SHOES", 2): {"BAGS", "SHOES"},
+				api.PathKey(api.CategoryDimension.Column, "SHOES", 3): {"HATS", "BAGS", "SHOES"},
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			tr := NewTracker(24*time.Hour, test.maxLength)
+			rc := api.RecentCategories{}
+
+			computed := make(map[string][]string)
+			for _, ut := range test.tags {
+				events, _ := tr.update(&rc, ut)
+				for _, e := range events {
+					if e.key == api.PathKey(api.BrandDimension.Column, "Nike", len(e.value.Steps)) {
+						continue
+					}
+					computed[e.key] = e.value.Steps
+				}
+			}
+
+			if !reflect.DeepEqual(test.expected, computed) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, computed)
+			}
+		})
+	}
+}
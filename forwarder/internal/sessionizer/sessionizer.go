@@ -0,0 +1,71 @@
+package sessionizer
+
+import (
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/session"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// Sessionizer keeps the current session of each cookie and reports session starts and extensions
+// to the bucket the session started in.
+type Sessionizer struct {
+	gap time.Duration
+}
+
+func NewSessionizer(gap time.Duration) *Sessionizer {
+	return &Sessionizer{
+		gap: gap,
+	}
+}
+
+func (sz *Sessionizer) Track(ctx goka.Context, msg interface{}) {
+	var s api.Session
+
+	v := ctx.Value()
+	if v != nil {
+		s = v.(api.Session)
+	}
+
+	ut, ok := msg.(*api.UserTag)
+	if !ok {
+		klog.Errorf("received message's type is not of type UserTag")
+		return
+	}
+
+	// REFUNDs only retract from the current session, ended sessions aren't kept.
+	if ut.Action == api.REFUND {
+		if session.Remove(&s, ut) {
+			ctx.SetValue(s)
+		}
+		return
+	}
+
+	if ut.Action != api.VIEW && ut.Action != api.BUY {
+		return
+	}
+
+	if !session.Continues(s, ut, sz.gap) {
+		if !s.Start.IsZero() && ut.Time.Before(s.Start) {
+			klog.V(3).InfoS("ignoring user tag older than the current session", "cookie", ut.Cookie, "time", ut.Time)
+			return
+		}
+
+		s = api.Session{}
+		session.Add(&s, ut)
+		ctx.Emit(kafka.SessionTopic, util.GetBucketKey(s.Start.Truncate(time.Minute)), api.SessionEvent{Started: true})
+		ctx.SetValue(s)
+		return
+	}
+
+	start, length := s.Start, s.End.Sub(s.Start)
+	session.Add(&s, ut)
+	if extension := s.End.Sub(s.Start) - length; extension > 0 {
+		ctx.Emit(kafka.SessionTopic, util.GetBucketKey(start.Truncate(time.Minute)), api.SessionEvent{Extension: extension})
+	}
+
+	ctx.SetValue(s)
+}
@@ -7,10 +7,17 @@ import (
 	as "github.com/aerospike/aerospike-client-go/v6"
 	"github.com/lovoo/goka"
 	"github.com/lovoo/goka/codec"
+	"github.com/rzetelskik/allezon-analytics/service/internal/service/identity"
 	"github.com/rzetelskik/allezon-analytics/service/internal/service/server"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/action"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/aerospike"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/category"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/currency"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/feature"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/reload"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/segment"
 	"k8s.io/klog/v2"
 	"log"
 	"net/http"
@@ -22,6 +29,50 @@ import (
 	"time"
 )
 
+var (
+	actions = flag.String("actions", "", "path to the file listing the actions tracked besides VIEW, BUY and REFUND, user tags of other actions are rejected")
+
+	sessionGap       = flag.Duration("session-gap", 30*time.Minute, "inactivity gap which ends a session")
+	interestHalfLife = flag.Duration("interest-half-life", 7*24*time.Hour, "time after which brand and category interest scores halve")
+	features         = flag.String("features", "", "path to the feature definitions file, feature counters aren't kept if it's empty")
+	segments         = flag.String("segments", "", "path to the segment definitions file, segments aren't evaluated if it's empty")
+
+	dimensionGroups = flag.String("dimension-groups", "", "comma-separated optional dimension groups aggregates can be filtered by, it has to be the one the forwarder aggregates")
+
+	categoryHierarchy      = flag.String("category-hierarchy", "", "path to the JSON file listing the parents of categories, it has to be the one the forwarder rolls category aggregates up along")
+	categoryReloadInterval = flag.Duration("category-reload-interval", time.Minute, "how often the category hierarchy file is checked for modifications")
+
+	exchangeRates               = flag.String("exchange-rates", "", "path to the JSON file with the exchange rates to the base currency, prices are summed as they are if it's empty")
+	exchangeRatesReloadInterval = flag.Duration("exchange-rates-reload-interval", time.Minute, "how often the exchange rates file is checked for modifications")
+)
+
+func newView(table goka.Table, codec goka.Codec, opts ...goka.ViewOption) *goka.View {
+	view, err := goka.NewView(
+		[]string{kafka.Bootstrap},
+		table,
+		codec,
+		opts...,
+	)
+	if err != nil {
+		klog.Fatalf("can't create view of %s: %v", table, err)
+	}
+
+	return view
+}
+
+func newEmitter(topic goka.Stream, codec goka.Codec) *goka.Emitter {
+	emitter, err := goka.NewEmitter(
+		[]string{kafka.Bootstrap},
+		topic,
+		codec,
+	)
+	if err != nil {
+		klog.Fatalf("can't create emitter of %s: %v", topic, err)
+	}
+
+	return emitter
+}
+
 func main() {
 	var err error
 
@@ -36,6 +87,17 @@ func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	log.SetOutput(os.Stdout)
 
+	if len(*actions) != 0 {
+		_, err = action.Load(*actions)
+		if err != nil {
+			klog.Fatalf("can't load actions: %v", err)
+		}
+	}
+
+	if *interestHalfLife <= 0 {
+		klog.Fatalf("interest half-life has to be positive")
+	}
+
 	stopCh := make(chan struct{})
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -71,45 +133,108 @@ func main() {
 		Compress:  true,
 	}
 
-	emitter, err := goka.NewEmitter(
-		[]string{kafka.Bootstrap},
-		kafka.UserProfileTopic,
-		new(codec.Bytes),
-	)
-	if err != nil {
-		klog.Fatalf("can't create emitter: %v", err)
+	identityStore := &aerospike.AerospikeStore[api.IdentityNode]{
+		Client:    asClient,
+		Policy:    as.NewPolicy(),
+		Namespace: aerospike.Namespace,
+		Set:       aerospike.IdentitySet,
+		Compress:  true,
+	}
+
+	emitters := server.Emitters{
+		UserTags: newEmitter(kafka.UserProfileTopic, new(codec.Bytes)),
+		Segments: newEmitter(kafka.SegmentTopic, new(api.SegmentEventCodec)),
+		Catalog:  newEmitter(goka.Stream(kafka.CatalogTable), new(api.CatalogProductCodec)),
 	}
 	defer func() {
-		err := emitter.Finish()
-		if err != nil {
-			klog.Errorf("can't finish emitter: %v", err)
+		for _, e := range emitters.List() {
+			err := e.Finish()
+			if err != nil {
+				klog.Errorf("can't finish emitter: %v", err)
+			}
 		}
 	}()
 
-	view, err := goka.NewView(
-		[]string{kafka.Bootstrap},
-		kafka.SinkTable,
-		new(api.UserAggregatesCodec),
-	)
-	if err != nil {
-		klog.Fatalf("can't create view: %v", err)
+	feed := server.NewChangeFeed()
+	views := server.Views{
+		Aggregates:   newView(kafka.SinkTable, new(api.UserAggregatesCodec), goka.WithViewCallback(feed.Update)),
+		Watermarks:   newView(kafka.WatermarkTable, new(api.WatermarksCodec)),
+		Funnel:       newView(kafka.FunnelSinkTable, new(api.FunnelAggregatesCodec)),
+		Sessions:     newView(kafka.SessionSinkTable, new(api.SessionAggregatesCodec)),
+		Cooccurrence: newView(kafka.CooccurrenceSinkTable, new(api.CooccurrencesCodec)),
+		Anomalies:    newView(kafka.AnomalySinkTable, new(api.AnomaliesCodec)),
+		Attributed:   newView(kafka.AttributionSinkTable, new(api.UserAggregatesCodec)),
+		Cohorts:      newView(kafka.CohortSinkTable, new(api.CohortAggregatesCodec)),
+		Segments:     newView(kafka.SegmentSinkTable, new(api.SegmentMemberCodec)),
+		Paths:        newView(kafka.PathSinkTable, new(api.PathsCodec)),
 	}
 
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	for _, v := range views.List() {
+		v := v
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		err = view.Run(ctx)
+			err := v.Run(ctx)
+			if err != nil {
+				close(stopCh)
+				klog.Fatalf("can't run view %s: %v", v.Topic(), err)
+			}
+		}()
+	}
+
+	config := server.Config{
+		SessionGap:       *sessionGap,
+		InterestHalfLife: *interestHalfLife,
+	}
+	config.Dimensions, err = api.EnabledDimensions(*dimensionGroups)
+	if err != nil {
+		klog.Fatalf("can't parse dimension groups: %v", err)
+	}
+	if len(*features) != 0 {
+		config.Features, err = feature.Load(*features)
 		if err != nil {
-			close(stopCh)
-			klog.Fatalf("can't run view: %v", err)
+			klog.Fatalf("can't load features: %v", err)
 		}
-	}()
+	}
+	if len(*segments) != 0 {
+		config.Segments, err = segment.Load(*segments)
+		if err != nil {
+			klog.Fatalf("can't load segments: %v", err)
+		}
+	}
+
+	if len(*categoryHierarchy) != 0 {
+		config.Categories, err = reload.NewFile(*categoryHierarchy, category.Parse)
+		if err != nil {
+			klog.Fatalf("can't load category hierarchy: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config.Categories.Run(ctx, *categoryReloadInterval)
+		}()
+	}
+
+	if len(*exchangeRates) != 0 {
+		config.ExchangeRates, err = reload.NewFile(*exchangeRates, currency.Parse)
+		if err != nil {
+			klog.Fatalf("can't load exchange rates: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config.ExchangeRates.Run(ctx, *exchangeRatesReloadInterval)
+		}()
+	}
 
-	srv := server.NewHTTPServer(":8080", userProfileStore, emitter, view)
+	srv := server.NewHTTPServer(":8080", userProfileStore, identity.NewGraph(identityStore), emitters, views, feed, config)
 
 	wg.Add(1)
 	go func() {
@@ -0,0 +1,277 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	as "github.com/aerospike/aerospike-client-go/v6"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+	"sort"
+	"strings"
+)
+
+const (
+	cookiePrefix = "cookie:"
+	loginPrefix  = "login:"
+
+	// maxRetries bounds the retries of read-modify-writes and of unions interleaved with other unions.
+	maxRetries = 5
+)
+
+var errNotRoot = errors.New("node isn't a root")
+
+// CookieNode returns the key of a cookie's node.
+func CookieNode(cookie string) string {
+	return cookiePrefix + cookie
+}
+
+// LoginNode returns the key of a login ID's node.
+func LoginNode(loginID string) string {
+	return loginPrefix + loginID
+}
+
+// less orders the nodes, so that login IDs become the roots of their components.
+// Parents always precede their children, which keeps the graph acyclic under concurrent unions.
+func less(a, b string) bool {
+	al, bl := strings.HasPrefix(a, loginPrefix), strings.HasPrefix(b, loginPrefix)
+	if al != bl {
+		return al
+	}
+	return a < b
+}
+
+// Store persists the nodes of the graph. Getting a missing node with errorOnNotFound fails with as.ErrKeyNotFound.
+type Store interface {
+	Get(key string, i *api.IdentityNode, errorOnNotFound bool) error
+	RMWWithGenCheck(key string, maxRetries int, i *api.IdentityNode, modify func(*api.IdentityNode) error) error
+}
+
+// Graph is a union-find structure of linked cookies and login IDs.
+type Graph struct {
+	store Store
+}
+
+func NewGraph(store Store) *Graph {
+	return &Graph{store: store}
+}
+
+// initNode makes a node which hasn't been stored yet the root of a component holding just itself.
+func initNode(key string, n *api.IdentityNode) {
+	if len(n.Parent) == 0 && n.Cookies == nil && strings.HasPrefix(key, cookiePrefix) {
+		n.Cookies = []string{strings.TrimPrefix(key, cookiePrefix)}
+	}
+}
+
+// Find returns the key and the node of the root of the given node's component without modifying the graph.
+// Cookies of attached nodes whose unions were interrupted are counted as the root's.
+func (g *Graph) Find(key string) (string, api.IdentityNode, error) {
+	root, n, _, pending, err := g.find(key)
+	if err != nil {
+		return "", api.IdentityNode{}, err
+	}
+
+	for _, cookies := range pending {
+		n.Cookies = mergeCookies(n.Cookies, cookies)
+	}
+
+	return root, n, nil
+}
+
+// find follows the parents of the given node to the root of its component. It returns the nodes on the path
+// and the cookies of the attached ones, which are still to be handed over to the root.
+func (g *Graph) find(key string) (string, api.IdentityNode, []string, map[string][]string, error) {
+	path := make([]string, 0)
+	pending := make(map[string][]string)
+
+	for {
+		n := api.IdentityNode{}
+		err := g.store.Get(key, &n, true)
+		if err != nil && !errors.Is(err, as.ErrKeyNotFound) {
+			return "", api.IdentityNode{}, nil, nil, fmt.Errorf("can't get node %q: %w", key, err)
+		}
+		initNode(key, &n)
+
+		if len(n.Parent) == 0 {
+			return key, n, path, pending, nil
+		}
+
+		if len(n.Cookies) > 0 {
+			pending[key] = n.Cookies
+		}
+		path = append(path, key)
+		key = n.Parent
+	}
+}
+
+// findAndRepair returns the root of the given node's component like Find. Cookies of attached nodes whose unions
+// were interrupted are handed over to the root and the nodes on the path are pointed directly at it.
+func (g *Graph) findAndRepair(key string) (string, error) {
+	root, _, path, pending, err := g.find(key)
+	if err != nil {
+		return "", err
+	}
+
+	for node, cookies := range pending {
+		err = g.handOver(node, root, cookies)
+		if err != nil {
+			klog.V(2).InfoS("can't hand over cookies", "node", node, "err", err)
+		}
+	}
+
+	g.compress(path[:max(len(path)-1, 0)], root)
+
+	return root, nil
+}
+
+// compress points the nodes at the root. The root stays their ancestor whatever happened since they were read,
+// so failures only leave the paths longer.
+func (g *Graph) compress(nodes []string, root string) {
+	for _, key := range nodes {
+		err := g.store.RMWWithGenCheck(key, maxRetries, &api.IdentityNode{}, func(n *api.IdentityNode) error {
+			if len(n.Parent) != 0 {
+				n.Parent = root
+			}
+			return nil
+		})
+		if err != nil {
+			klog.V(2).InfoS("can't compress identity path", "node", key, "err", err)
+		}
+	}
+}
+
+// Link merges the components of the given nodes.
+func (g *Graph) Link(keys ...string) error {
+	for i := 1; i < len(keys); i++ {
+		err := g.union(keys[0], keys[i])
+		if err != nil {
+			return fmt.Errorf("can't link %q with %q: %w", keys[0], keys[i], err)
+		}
+	}
+
+	return nil
+}
+
+func (g *Graph) union(a, b string) error {
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		ra, err := g.findAndRepair(a)
+		if err != nil {
+			return err
+		}
+		rb, err := g.findAndRepair(b)
+		if err != nil {
+			return err
+		}
+		if ra == rb {
+			return nil
+		}
+
+		root, child := ra, rb
+		if less(child, root) {
+			root, child = child, root
+		}
+
+		// The child is attached first, so that it can't be absorbed by another union in the meantime. It keeps its cookies
+		// until the root has them, so that they aren't lost if the union is interrupted.
+		var cookies []string
+		err = g.store.RMWWithGenCheck(child, maxRetries, &api.IdentityNode{}, func(n *api.IdentityNode) error {
+			initNode(child, n)
+			if len(n.Parent) != 0 && n.Parent != root {
+				return errNotRoot
+			}
+			n.Parent = root
+			cookies = n.Cookies
+			return nil
+		})
+		if errors.Is(err, errNotRoot) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("can't attach %q to %q: %w", child, root, err)
+		}
+
+		return g.handOver(child, root, cookies)
+	}
+
+	return errors.New("max retries exceeded")
+}
+
+// handOver adds the cookies of an attached node to the component of the root and clears them from the node.
+// Nodes which still have cookies after being attached are handed over again by the next link passing through them,
+// e.g. when the interrupted link is retried.
+func (g *Graph) handOver(key string, root string, cookies []string) error {
+	if len(cookies) == 0 {
+		return nil
+	}
+
+	err := g.addCookies(root, cookies)
+	if err != nil {
+		return err
+	}
+
+	// Only roots gain cookies, so the node can't have gained any since it was attached.
+	err = g.store.RMWWithGenCheck(key, maxRetries, &api.IdentityNode{}, func(n *api.IdentityNode) error {
+		n.Cookies = nil
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't clear cookies of %q: %w", key, err)
+	}
+
+	return nil
+}
+
+// addCookies adds the cookies to the component of the root, following it if it has been attached to another one since.
+func (g *Graph) addCookies(key string, cookies []string) error {
+	for {
+		var next string
+		err := g.store.RMWWithGenCheck(key, maxRetries, &api.IdentityNode{}, func(n *api.IdentityNode) error {
+			initNode(key, n)
+			if len(n.Parent) != 0 {
+				next = n.Parent
+				return errNotRoot
+			}
+			n.Cookies = mergeCookies(n.Cookies, cookies)
+			return nil
+		})
+		if errors.Is(err, errNotRoot) {
+			key = next
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("can't add cookies to %q: %w", key, err)
+		}
+
+		return nil
+	}
+}
+
+func mergeCookies(xs []string, ys []string) []string {
+	seen := make(map[string]bool, len(xs)+len(ys))
+	res := make([]string, 0, len(xs)+len(ys))
+	for _, c := range append(append([]string{}, xs...), ys...) {
+		if !seen[c] {
+			seen[c] = true
+			res = append(res, c)
+		}
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// Resolve returns the user ID of the cookie and the cookies linked with it.
+func (g *Graph) Resolve(cookie string) (string, []string, error) {
+	root, n, err := g.Find(CookieNode(cookie))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return root, n.Cookies, nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,208 @@
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	as "github.com/aerospike/aerospike-client-go/v6"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// memoryStore is a Store keeping serialized nodes in memory. Writes of the failing node fail.
+type memoryStore struct {
+	mu      sync.Mutex
+	nodes   map[string][]byte
+	failing string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{nodes: make(map[string][]byte)}
+}
+
+func (s *memoryStore) Get(key string, i *api.IdentityNode, errorOnNotFound bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.nodes[key]
+	if !ok && errorOnNotFound {
+		return as.ErrKeyNotFound
+	}
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(data, i)
+}
+
+func (s *memoryStore) RMWWithGenCheck(key string, maxRetries int, i *api.IdentityNode, modify func(*api.IdentityNode) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, ok := s.nodes[key]; ok {
+		err := json.Unmarshal(data, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	if key == s.failing {
+		return errors.New("write failed")
+	}
+
+	err := modify(i)
+	if err != nil {
+		return err
+	}
+
+	s.nodes[key], err = json.Marshal(i)
+	return err
+}
+
+func TestGraph(t *testing.T) {
+	ts := []struct {
+		name           string
+		links          [][]string
+		cookie         string
+		expectedUserID string
+		expectedLinked []string
+	}{
+		{
+			name:           "Unlinked cookie is alone",
+			links:          [][]string{},
+			cookie:         "a",
+			expectedUserID: CookieNode("a"),
+			expectedLinked: []string{"a"},
+		},
+		{
+			name: "Cookie pairs are linked transitively",
+			links: [][]string{
+				{CookieNode("c"), CookieNode("b")},
+				{CookieNode("d"), CookieNode("e")},
+				{CookieNode("b"), CookieNode("e")},
+				{CookieNode("x"), CookieNode("y")},
+			},
+			cookie:         "d",
+			expectedUserID: CookieNode("b"),
+			expectedLinked: []string{"b", "c", "d", "e"},
+		},
+		{
+			name: "Login ID becomes the user ID",
+			links: [][]string{
+				{CookieNode("a"), CookieNode("b")},
+				{LoginNode("u"), CookieNode("b"), CookieNode("c")},
+				{CookieNode("a"), CookieNode("c")},
+			},
+			cookie:         "a",
+			expectedUserID: LoginNode("u"),
+			expectedLinked: []string{"a", "b", "c"},
+		},
+		{
+			name: "Login IDs link their cookies",
+			links: [][]string{
+				{LoginNode("u"), CookieNode("a")},
+				{LoginNode("v"), CookieNode("b")},
+				{LoginNode("v"), CookieNode("a")},
+			},
+			cookie:         "b",
+			expectedUserID: LoginNode("u"),
+			expectedLinked: []string{"a", "b"},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewGraph(newMemoryStore())
+			for _, l := range test.links {
+				err := g.Link(l...)
+				if err != nil {
+					t.Fatalf("can't link: %v", err)
+				}
+			}
+
+			userID, linked, err := g.Resolve(test.cookie)
+			if err != nil {
+				t.Fatalf("can't resolve: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.expectedUserID, userID) || !reflect.DeepEqual(test.expectedLinked, linked) {
+				t.Errorf("expected and computed results differ: %v %v, %v %v", test.expectedUserID, test.expectedLinked, userID, linked)
+			}
+		})
+	}
+}
+
+func TestGraphInterruptedLink(t *testing.T) {
+	t.Parallel()
+
+	s := newMemoryStore()
+	g := NewGraph(s)
+
+	// The child gets attached, but its cookies can't be added to the root.
+	s.failing = CookieNode("a")
+	err := g.Link(CookieNode("a"), CookieNode("b"))
+	if err == nil {
+		t.Fatalf("expected the link to fail")
+	}
+
+	s.failing = ""
+	err = g.Link(CookieNode("a"), CookieNode("b"))
+	if err != nil {
+		t.Fatalf("can't link: %v", err)
+	}
+
+	expected := []string{"a", "b"}
+	for _, cookie := range expected {
+		_, res, err := g.Resolve(cookie)
+		if err != nil {
+			t.Fatalf("can't resolve cookie: %v", err)
+		}
+		if !reflect.DeepEqual(expected, res) {
+			t.Errorf("expected and computed results differ: %v, %v", expected, res)
+		}
+	}
+}
+
+func TestGraphResolveIsReadOnly(t *testing.T) {
+	t.Parallel()
+
+	s := newMemoryStore()
+	g := NewGraph(s)
+
+	err := g.Link(CookieNode("a"), CookieNode("b"), CookieNode("c"))
+	if err != nil {
+		t.Fatalf("can't link: %v", err)
+	}
+
+	// The child gets attached, but its cookies can't be added to the root.
+	s.failing = CookieNode("a")
+	err = g.Link(CookieNode("a"), CookieNode("d"))
+	if err == nil {
+		t.Fatalf("expected the link to fail")
+	}
+	s.failing = ""
+
+	stored := make(map[string]string, len(s.nodes))
+	for k, v := range s.nodes {
+		stored[k] = string(v)
+	}
+
+	_, res, err := g.Resolve("d")
+	if err != nil {
+		t.Fatalf("can't resolve cookie: %v", err)
+	}
+
+	expected := []string{"a", "b", "c", "d"}
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected and computed results differ: %v, %v", expected, res)
+	}
+
+	computed := make(map[string]string, len(s.nodes))
+	for k, v := range s.nodes {
+		computed[k] = string(v)
+	}
+	if !reflect.DeepEqual(stored, computed) {
+		t.Errorf("expected resolving not to modify the stored nodes: %v, %v", stored, computed)
+	}
+}
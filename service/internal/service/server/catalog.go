@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"io"
+	"k8s.io/klog/v2"
+	"net/http"
+	"strconv"
+)
+
+func (s *server) CatalogProductPutHandler(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseUint(mux.Vars(r)["product_id"], 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("can't parse product_id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		klog.ErrorS(err, "can't read io")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cp := api.CatalogProduct{}
+	err = json.Unmarshal(payload, &cp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if cp.Margin < 0 || cp.Margin > 1 {
+		http.Error(w, "margin has to be between 0 and 1", http.StatusBadRequest)
+		return
+	}
+
+	// The forwarder joins user tags with the catalog by the product ids as formatted by the user tags.
+	err = s.emitters.Catalog.EmitSync(strconv.FormatUint(productID, 10), cp)
+	if err != nil {
+		klog.ErrorS(err, "can't emit catalog product", "product_id", productID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
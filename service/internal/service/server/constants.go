@@ -1,7 +1,62 @@
 package server
 
+import "time"
+
 // Constants specified by project requirements
 const (
 	// UserTagPerActionLimit specifies the upper limit of UserTags that have to be stored
 	UserTagPerActionLimit = 200
 )
+
+const (
+	// TopDefaultK specifies the number of items returned by top queries unless requested otherwise
+	TopDefaultK = 10
+
+	// RecommendationsDefaultLimit specifies the number of recommended products returned unless requested otherwise
+	RecommendationsDefaultLimit = 10
+
+	// RecommendationsSeedLimit specifies the number of the most recently viewed products recommendations for a cookie are based on
+	RecommendationsSeedLimit = 10
+
+	// AggregatesStreamRefreshInterval specifies how often aggregate streams open and close buckets
+	AggregatesStreamRefreshInterval = time.Second
+
+	// AggregatesStreamBufferSize specifies the number of table updates buffered for each aggregate stream
+	AggregatesStreamBufferSize = 1024
+
+	// ForecastDefaultHorizon specifies how far ahead aggregates are forecast unless requested otherwise
+	ForecastDefaultHorizon = time.Hour
+
+	// ForecastMaxHorizon specifies how far ahead aggregates can be forecast
+	ForecastMaxHorizon = 24 * time.Hour
+
+	// ForecastDefaultConfidence specifies the confidence level of forecast intervals unless requested otherwise
+	ForecastDefaultConfidence = 0.95
+
+	// ForecastHistory specifies how many of the most recent closed buckets forecasts are fitted on
+	ForecastHistory = 3 * time.Hour
+
+	// ForecastSeason specifies the number of buckets in a season of the forecast model
+	ForecastSeason = 60
+
+	// CohortsDefaultPeriods specifies the number of retention periods returned unless requested otherwise
+	CohortsDefaultPeriods = 7
+
+	// PathsDefaultLength specifies the number of steps of the paths returned unless requested otherwise
+	PathsDefaultLength = 3
+
+	// InterestsDefaultLimit specifies the number of brands and categories returned by interest queries unless requested otherwise
+	InterestsDefaultLimit = 10
+
+	// InterestsPerKindLimit specifies the number of the highest brand and category scores kept in user profiles
+	InterestsPerKindLimit = 100
+
+	// MergedUserProfileCookieLimit specifies the number of linked cookies whose profiles are merged into a user's profile
+	MergedUserProfileCookieLimit = 32
+
+	// SegmentMembersDefaultLimit specifies the number of segment members returned unless requested otherwise
+	SegmentMembersDefaultLimit = 1000
+
+	// caughtUpOffsetLag is the offset lag of a view partition which has consumed all messages up to the high watermark.
+	caughtUpOffsetLag = 1
+)
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/feature"
+	"k8s.io/klog/v2"
+	"net/http"
+	"time"
+)
+
+// FeaturesGetHandler returns the values of the defined features of a cookie over the windows ending at the optional
+// 'as_of' parameter, or now if it's missing, so that counters of idle cookies go down as their windows slide.
+func (s *server) FeaturesGetHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	cookie := mux.Vars(r)["cookie"]
+
+	values := r.URL.Query()
+
+	asOf := time.Now().UTC()
+	if values.Has("as_of") {
+		asOf, err = api.ParseDatetime(values.Get("as_of"))
+		if err != nil {
+			http.Error(w, "optional parameter 'as_of' is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	up := api.UserProfile{}
+	err = s.upStore.Get(cookie, &up, false)
+	if err != nil {
+		klog.ErrorS(err, "can't get user profile", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fr := api.FeaturesResponse{
+		Cookie:   cookie,
+		AsOf:     asOf,
+		Features: feature.Values(up.Features, s.config.Features, asOf),
+	}
+
+	payload, err := json.Marshal(fr)
+	if err != nil {
+		klog.ErrorS(err, "can't marshal data", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/rzetelskik/allezon-analytics/service/internal/service/identity"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"io"
+	"k8s.io/klog/v2"
+	"net/http"
+)
+
+// getMergedUserProfile merges the profiles of the given cookie and at most MergedUserProfileCookieLimit-1 cookies linked with it.
+// The merged user tags of each action keep the descending time order and the per-action limit of a single profile.
+func (s *server) getMergedUserProfile(cookie string) (api.UserProfile, error) {
+	_, linked, err := s.identities.Resolve(cookie)
+	if err != nil {
+		return api.UserProfile{}, err
+	}
+
+	cookies := []string{cookie}
+	for _, c := range linked {
+		if c != cookie {
+			cookies = append(cookies, c)
+		}
+	}
+	cookies = HeadSlice(cookies, MergedUserProfileCookieLimit)
+
+	newer := func(a, b api.UserTag) bool {
+		return a.Time.After(b.Time)
+	}
+
+	merged := api.UserProfile{
+		Views: make([]api.UserTag, 0),
+		Buys:  make([]api.UserTag, 0),
+	}
+	for _, c := range cookies {
+		up := api.UserProfile{}
+		err = s.upStore.Get(c, &up, false)
+		if err != nil {
+			return api.UserProfile{}, err
+		}
+
+		for _, a := range api.ProfileActions() {
+			merged.SetTags(a, HeadSlice(MergeSortedSlices(merged.Tags(a), up.Tags(a), newer), UserTagPerActionLimit))
+		}
+	}
+
+	return merged, nil
+}
+
+func (s *server) IdentitiesLinkPostHandler(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		klog.ErrorS(err, "can't read io")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	req := api.IdentityLinkRequest{}
+	err = json.Unmarshal(payload, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nodes := make([]string, 0, len(req.Cookies)+1)
+	if len(req.LoginID) != 0 {
+		nodes = append(nodes, identity.LoginNode(req.LoginID))
+	}
+	for _, c := range req.Cookies {
+		if len(c) == 0 {
+			http.Error(w, "cookies can't be empty", http.StatusBadRequest)
+			return
+		}
+		nodes = append(nodes, identity.CookieNode(c))
+	}
+	if len(req.Cookies) == 0 || len(nodes) < 2 {
+		http.Error(w, "either two cookies or a login ID and a cookie are required", http.StatusBadRequest)
+		return
+	}
+
+	err = s.identities.Link(nodes...)
+	if err != nil {
+		klog.ErrorS(err, "can't link identities", "login_id", req.LoginID, "cookies", req.Cookies)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) IdentitiesGetHandler(w http.ResponseWriter, r *http.Request) {
+	cookie := mux.Vars(r)["cookie"]
+
+	userID, cookies, err := s.identities.Resolve(cookie)
+	if err != nil {
+		klog.ErrorS(err, "can't resolve identity", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := json.Marshal(api.IdentityResponse{
+		Cookie:  cookie,
+		UserID:  userID,
+		Cookies: cookies,
+	})
+	if err != nil {
+		klog.ErrorS(err, "can't marshal data", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
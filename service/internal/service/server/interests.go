@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/interest"
+	"k8s.io/klog/v2"
+	"net/http"
+	"strconv"
+)
+
+// UserInterestsGetHandler returns the brands and categories a cookie is the most interested in, ranked by their scores.
+func (s *server) UserInterestsGetHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	cookie := mux.Vars(r)["cookie"]
+
+	values := r.URL.Query()
+
+	limit := InterestsDefaultLimit
+	if values.Has("limit") {
+		limit, err = strconv.Atoi(values.Get("limit"))
+		if err != nil || limit <= 0 {
+			http.Error(w, "optional parameter 'limit' is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	up := api.UserProfile{}
+	err = s.upStore.Get(cookie, &up, false)
+	if err != nil {
+		klog.ErrorS(err, "can't get user profile", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	asOf := up.Interests.Brands.Time
+	if up.Interests.Categories.Time.After(asOf) {
+		asOf = up.Interests.Categories.Time
+	}
+
+	uir := api.UserInterestsResponse{
+		Cookie:     cookie,
+		AsOf:       asOf,
+		Brands:     HeadSlice(interest.Rank(interest.DecayTo(up.Interests.Brands, asOf, s.config.InterestHalfLife)), limit),
+		Categories: HeadSlice(interest.Rank(interest.DecayTo(up.Interests.Categories, asOf, s.config.InterestHalfLife)), limit),
+	}
+
+	payload, err := json.Marshal(uir)
+	if err != nil {
+		klog.ErrorS(err, "can't marshal data", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
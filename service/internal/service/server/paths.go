@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"k8s.io/klog/v2"
+	"net/http"
+	"strconv"
+)
+
+// PathsGetHandler returns the most common paths of browsed categories leading to purchases of a category or a brand.
+func (s *server) PathsGetHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	values := r.URL.Query()
+
+	pr := api.PathsResponse{
+		CategoryID: values.Get(string(api.CategoryDimension.Column)),
+		BrandID:    values.Get(string(api.BrandDimension.Column)),
+		Length:     PathsDefaultLength,
+		Paths:      make([]api.PathRow, 0),
+	}
+
+	var target api.AggregateColumn
+	var value string
+	switch {
+	case len(pr.CategoryID) != 0 && len(pr.BrandID) == 0:
+		target, value = api.CategoryDimension.Column, pr.CategoryID
+	case len(pr.BrandID) != 0 && len(pr.CategoryID) == 0:
+		target, value = api.BrandDimension.Column, pr.BrandID
+	default:
+		http.Error(w, "exactly one of the parameters 'category_id' and 'brand_id' is required", http.StatusBadRequest)
+		return
+	}
+
+	if values.Has("length") {
+		pr.Length, err = strconv.Atoi(values.Get("length"))
+		if err != nil || pr.Length < 2 {
+			http.Error(w, "optional parameter 'length' is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	k := TopDefaultK
+	if values.Has("k") {
+		k, err = strconv.Atoi(values.Get("k"))
+		if err != nil || k <= 0 {
+			http.Error(w, "optional parameter 'k' is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	v, err := s.views.Paths.Get(api.PathKey(target, value, pr.Length))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if v != nil {
+		p := v.(api.Paths)
+		for _, c := range p.Sketch().Top(k) {
+			pr.Paths = append(pr.Paths, api.PathRow{
+				Steps: api.SplitPath(c.Item),
+				Count: api.AggregateValue(c.Count),
+			})
+		}
+	}
+
+	payload, err := json.Marshal(pr)
+	if err != nil {
+		klog.Errorf("can't marshall paths response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/segment"
+	"k8s.io/klog/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// emitSegmentEvents publishes the segments a cookie entered and exited as of the given time.
+func (s *server) emitSegmentEvents(cookie string, entered []string, exited []string, memberships map[string]time.Time, t time.Time) {
+	for _, ids := range []struct {
+		ids     []string
+		entered bool
+	}{{entered, true}, {exited, false}} {
+		for _, id := range ids.ids {
+			se := api.SegmentEvent{
+				Segment: id,
+				Cookie:  cookie,
+				Entered: ids.entered,
+				Time:    t,
+			}
+			if ids.entered {
+				se.Until = memberships[id]
+			}
+
+			err := s.emitters.Segments.EmitSync(api.SegmentMemberKey(id, cookie), se)
+			if err != nil {
+				klog.ErrorS(err, "can't emit segment event", "segment", id, "cookie", cookie)
+			}
+		}
+	}
+}
+
+// UserSegmentsGetHandler returns the segments a cookie belongs to now.
+// Cookies which stayed idle since their profile was last updated may have left some of the segments stored with it.
+func (s *server) UserSegmentsGetHandler(w http.ResponseWriter, r *http.Request) {
+	cookie := mux.Vars(r)["cookie"]
+
+	up := api.UserProfile{}
+	err := s.upStore.Get(cookie, &up, false)
+	if err != nil {
+		klog.ErrorS(err, "can't get user profile", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(s.config.Segments) > 0 {
+		up.Segments = segment.Evaluate(s.config.Segments, &up, time.Now().UTC())
+	}
+
+	usr := api.UserSegmentsResponse{
+		Cookie:   cookie,
+		Segments: up.Segments,
+	}
+	if usr.Segments == nil {
+		usr.Segments = make([]string, 0)
+	}
+
+	payload, err := json.Marshal(usr)
+	if err != nil {
+		klog.ErrorS(err, "can't marshal data", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// SegmentMembersGetHandler lists the members of a segment sorted by their cookies.
+// Pages following the first one start after the cookie given in the 'after' parameter.
+// Memberships which have ended are left out.
+func (s *server) SegmentMembersGetHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	id := mux.Vars(r)["id"]
+
+	values := r.URL.Query()
+
+	limit := SegmentMembersDefaultLimit
+	if values.Has("limit") {
+		limit, err = strconv.Atoi(values.Get("limit"))
+		if err != nil || limit <= 0 {
+			http.Error(w, "optional parameter 'limit' is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+	after := values.Get("after")
+
+	// Member keys of a segment share the segment id followed by a slash, and '0' is the character following the slash.
+	// The range starts right after the last member of the previous page.
+	start := api.SegmentMemberKey(id, "")
+	if len(after) > 0 {
+		start = api.SegmentMemberKey(id, after) + "\x00"
+	}
+	it, err := s.views.Segments.IteratorWithRange(start, id+"0")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer it.Release()
+
+	// The iterator merges the partitions in key order, so the page is complete once it's full.
+	now := time.Now().UTC()
+	members := make([]api.SegmentMemberRow, 0)
+	for len(members) < limit && it.Next() {
+		v, err := it.Value()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if v == nil {
+			continue
+		}
+
+		sm := v.(api.SegmentMember)
+		if !sm.Active(now) {
+			continue
+		}
+
+		_, cookie := api.SplitSegmentMemberKey(it.Key())
+		members = append(members, api.SegmentMemberRow{
+			Cookie: cookie,
+			Since:  sm.Since,
+			Until:  sm.Until,
+		})
+	}
+	if err := it.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := json.Marshal(api.SegmentMembersResponse{
+		Segment: id,
+		Members: members,
+	})
+	if err != nil {
+		klog.Errorf("can't marshall segment members response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
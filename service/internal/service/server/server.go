@@ -7,23 +7,92 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/gorilla/mux"
 	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/service/internal/service/identity"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/aerospike"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/category"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/currency"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/feature"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/forecast"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/interest"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/reload"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/segment"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/session"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/topk"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/util"
 	"io"
 	"k8s.io/klog/v2"
 	"net/http"
+	"net/url"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// Views groups the views of processor tables the server reads from.
+type Views struct {
+	Aggregates   *goka.View
+	Watermarks   *goka.View
+	Funnel       *goka.View
+	Sessions     *goka.View
+	Cooccurrence *goka.View
+	Anomalies    *goka.View
+	Attributed   *goka.View
+	Cohorts      *goka.View
+	Segments     *goka.View
+	Paths        *goka.View
+}
+
+func (v Views) List() []*goka.View {
+	return []*goka.View{v.Aggregates, v.Watermarks, v.Funnel, v.Sessions, v.Cooccurrence, v.Anomalies, v.Attributed, v.Cohorts, v.Segments, v.Paths}
+}
+
+// Emitters groups the emitters the server publishes to.
+type Emitters struct {
+	UserTags *goka.Emitter
+	Segments *goka.Emitter
+	Catalog  *goka.Emitter
+}
+
+func (e Emitters) List() []*goka.Emitter {
+	return []*goka.Emitter{e.UserTags, e.Segments, e.Catalog}
+}
+
+// Config holds the tunables of the server.
+type Config struct {
+	// SessionGap is the inactivity gap which ends a session.
+	SessionGap time.Duration
+	// Segments are evaluated against the profiles updated by ingested user tags.
+	Segments []segment.Segment
+	// InterestHalfLife is the time after which interest scores halve.
+	InterestHalfLife time.Duration
+	// Features are the sliding-window counters kept in the user profiles.
+	Features []feature.Definition
+	// Dimensions are the ones the forwarder aggregates.
+	Dimensions []api.Dimension
+	// Categories is the hierarchy the forwarder rolls category aggregates up along, if it's configured.
+	Categories *reload.File[category.Hierarchy]
+	// ExchangeRates convert the prices of ingested user tags to the base currency, if they're configured.
+	ExchangeRates *reload.File[*currency.Rates]
+}
+
 type server struct {
-	upStore *aerospike.AerospikeStore[api.UserProfile]
-	emitter *goka.Emitter
-	view    *goka.View
+	upStore    *aerospike.AerospikeStore[api.UserProfile]
+	identities *identity.Graph
+	emitters   Emitters
+	views      Views
+	feed       *ChangeFeed
+	config     Config
 }
 
+// errPurchaseNotFound is returned for REFUNDs of BUYs missing from the user profile.
+// BUYs older than the ones kept in the profile are assumed to exist, REFUNDs of other missing BUYs, e.g. refunded ones, are rejected.
+var errPurchaseNotFound = errors.New("refunded BUY is missing from the user profile")
+
 func (s *server) UserTagsPostHandler(w http.ResponseWriter, r *http.Request) {
 	payload, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
@@ -41,6 +110,16 @@ func (s *server) UserTagsPostHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if (ut.Action == api.REFUND) != (ut.PurchaseTime != nil) {
+		http.Error(w, "purchase_time has to be given for REFUND user tags and only for them", http.StatusBadRequest)
+		return
+	}
+
+	// The base price is only sent along with the emitted user tag, the profile keeps the user tag as it was given.
+	ut.Product.BasePrice = nil
+	if ut.Action != api.REFUND {
+		s.convertPrice(&ut)
+	}
 
 	def := api.UserProfile{
 		Views: make([]api.UserTag, 0),
@@ -52,22 +131,77 @@ func (s *server) UserTagsPostHandler(w http.ResponseWriter, r *http.Request) {
 			return xs[i].Time.Before(x.Time)
 		}
 	}
+	var entered, exited []string
+	var memberships map[string]time.Time
+	var evaluated time.Time
 	modify := func(up *api.UserProfile) error {
+		var prevMemberships map[string]time.Time
+		if len(s.config.Segments) > 0 {
+			evaluated = time.Now().UTC()
+			prevMemberships = segment.Memberships(s.config.Segments, up, evaluated)
+		}
+
 		switch ut.Action {
-		case api.VIEW:
-			up.Views = HeadSlice(InsertIntoSortedSlice(ut, up.Views, f), UserTagPerActionLimit)
-		case api.BUY:
-			up.Buys = HeadSlice(InsertIntoSortedSlice(ut, up.Buys, f), UserTagPerActionLimit)
+		case api.REFUND:
+			// REFUNDs of BUYs which have already left the profile are retracted as they were given.
+			if !retractBuy(up, &ut) && !buyEvicted(up, *ut.PurchaseTime) {
+				return errPurchaseNotFound
+			}
+			s.convertPrice(&ut)
+		default:
+			stored := ut
+			stored.Product.BasePrice = nil
+			up.SetTags(ut.Action, HeadSlice(InsertIntoSortedSlice(stored, up.Tags(ut.Action), f), UserTagPerActionLimit))
+		}
+
+		if ut.Action != api.REFUND {
+			interest.Update(&up.Interests, &ut, s.config.InterestHalfLife, InterestsPerKindLimit)
+
+			if len(s.config.Features) > 0 {
+				feature.Update(&up.Features, s.config.Features, &ut)
+			}
+		} else if len(s.config.Features) > 0 {
+			feature.Retract(&up.Features, s.config.Features, &ut)
+		}
+
+		if len(s.config.Segments) > 0 {
+			prev := up.Segments
+			memberships = segment.Memberships(s.config.Segments, up, evaluated)
+			up.Segments = segment.IDs(memberships)
+			entered, exited = segment.Diff(prev, up.Segments)
+			// Memberships extended by the user tag are published again, so that they don't end early in the segment table.
+			entered = append(entered, segment.Extended(prevMemberships, memberships)...)
 		}
 
 		return nil
 	}
 	err = s.upStore.RMWWithGenCheck(ut.Cookie, 3, &def, modify)
 	if err != nil {
+		// REFUNDs are only emitted once their BUYs are retracted, so that they're never subtracted twice.
+		if errors.Is(err, errPurchaseNotFound) {
+			http.Error(w, errPurchaseNotFound.Error(), http.StatusNotFound)
+			return
+		}
 		klog.ErrorS(err, "can't update user profile", "cookie", ut.Cookie)
+		if ut.Action == api.REFUND {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		s.emitSegmentEvents(ut.Cookie, entered, exited, memberships, evaluated)
+	}
+
+	// REFUNDs may have been completed with the properties of their BUYs.
+	if ut.Action == api.REFUND || ut.Product.BasePrice != nil {
+		payload, err = json.Marshal(ut)
+		if err != nil {
+			klog.ErrorS(err, "can't marshal user tag", "cookie", ut.Cookie)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
-	err = s.emitter.EmitSync(ut.Cookie, payload)
+	err = s.emitters.UserTags.EmitSync(ut.Cookie, payload)
 	if err != nil {
 		klog.ErrorS(err, "can't emit to kafka")
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -77,6 +211,46 @@ func (s *server) UserTagsPostHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// convertPrice sets the base price of the user tag if exchange rates are configured.
+// Prices in currencies without an exchange rate add nothing in the base currency and are only summed in their own.
+func (s *server) convertPrice(ut *api.UserTag) {
+	if s.config.ExchangeRates == nil {
+		return
+	}
+
+	base, ok := s.config.ExchangeRates.Get().ToBase(int64(ut.Product.Price), ut.Product.Currency)
+	if !ok {
+		klog.V(3).InfoS("no exchange rate for the price's currency, it's only summed in its original currency", "cookie", ut.Cookie, "currency", ut.Product.Currency)
+	}
+	ut.Product.BasePrice = &base
+}
+
+// buyEvicted reports whether a BUY made at t would have left the profile, being older than all of the BUYs kept in it.
+func buyEvicted(up *api.UserProfile, t time.Time) bool {
+	return len(up.Buys) >= UserTagPerActionLimit && up.Buys[len(up.Buys)-1].Time.After(t)
+}
+
+// retractBuy removes the BUY the refund refers to from the profile and completes the refund with the properties of the BUY,
+// so that the refund retracts it from the same aggregates. It reports false if the BUY is missing from the profile,
+// e.g. because it's already been refunded.
+func retractBuy(up *api.UserProfile, refund *api.UserTag) bool {
+	for i, b := range up.Buys {
+		if !b.Time.Equal(*refund.PurchaseTime) || b.Product.ProductID != refund.Product.ProductID {
+			continue
+		}
+
+		refund.Country = b.Country
+		refund.Device = b.Device
+		refund.Origin = b.Origin
+		refund.Product = b.Product
+		up.Buys = append(up.Buys[:i], up.Buys[i+1:]...)
+
+		return true
+	}
+
+	return false
+}
+
 func (s *server) UserProfilesPostHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 
@@ -106,8 +280,21 @@ func (s *server) UserProfilesPostHandler(w http.ResponseWriter, r *http.Request)
 		limit = 200
 	}
 
+	var merged bool
+	if values.Has("merged") {
+		merged, err = strconv.ParseBool(values.Get("merged"))
+		if err != nil {
+			http.Error(w, "optional parameter 'merged' is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+
 	up := api.UserProfile{}
-	err = s.upStore.Get(cookie, &up, false)
+	if merged {
+		up, err = s.getMergedUserProfile(cookie)
+	} else {
+		err = s.upStore.Get(cookie, &up, false)
+	}
 	if err != nil {
 		klog.ErrorS(err, "can't get user profile", "cookie", cookie)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -118,12 +305,15 @@ func (s *server) UserProfilesPostHandler(w http.ResponseWriter, r *http.Request)
 		return (x.Time.After(lowerBound) || x.Time.Equal(lowerBound)) && x.Time.Before(upperBound)
 	}
 
-	up.Buys = HeadSlice(FilterSlice(up.Buys, filterFunc), limit)
-	up.Views = HeadSlice(FilterSlice(up.Views, filterFunc), limit)
+	for _, a := range api.ProfileActions() {
+		up.SetTags(a, HeadSlice(FilterSlice(up.Tags(a), filterFunc), limit))
+	}
 
 	upr := api.UserProfileResponse{
-		Cookie:      cookie,
-		UserProfile: up,
+		Cookie:  cookie,
+		Views:   up.Views,
+		Buys:    up.Buys,
+		Actions: up.Actions,
 	}
 
 	payload, err := json.Marshal(upr)
@@ -137,7 +327,53 @@ func (s *server) UserProfilesPostHandler(w http.ResponseWriter, r *http.Request)
 	w.Write(payload)
 }
 
-func (s *server) AggregatesPostHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) UserSessionsGetHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	cookie := mux.Vars(r)["cookie"]
+	values := r.URL.Query()
+
+	var lowerBound, upperBound time.Time
+	if values.Has("time_range") {
+		lowerBound, upperBound, err = api.ParseTimeRange(values.Get("time_range"))
+		if err != nil {
+			http.Error(w, fmt.Errorf("can't parse time range: %w", err).Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	up := api.UserProfile{}
+	err = s.upStore.Get(cookie, &up, false)
+	if err != nil {
+		klog.ErrorS(err, "can't get user profile", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sessions := session.Sessionize(up.Views, up.Buys, s.config.SessionGap)
+	if values.Has("time_range") {
+		sessions = FilterSlice(sessions, func(x api.Session) bool {
+			return (x.End.After(lowerBound) || x.End.Equal(lowerBound)) && x.Start.Before(upperBound)
+		})
+	}
+
+	usr := api.UserSessionsResponse{
+		Cookie:   cookie,
+		Sessions: sessions,
+	}
+
+	payload, err := json.Marshal(usr)
+	if err != nil {
+		klog.ErrorS(err, "can't marshal data", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (s *server) SessionAggregatesGetHandler(w http.ResponseWriter, r *http.Request) {
 	values := r.URL.Query()
 
 	if !values.Has("time_range") {
@@ -145,85 +381,296 @@ func (s *server) AggregatesPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	timeRange := values.Get("time_range")
-	lowerBound, upperBound, err := api.ParseTimeRange(timeRange)
+	lowerBound, upperBound, err := api.ParseTimeRange(values.Get("time_range"))
 	if err != nil {
 		http.Error(w, fmt.Errorf("can't parse time range: %w", err).Error(), http.StatusBadRequest)
 		return
 	}
 
-	// FIXME: validate time ranges
+	rows := make([]api.SessionAggregatesRow, 0)
+	for b := lowerBound; b.Before(upperBound); b = b.Add(time.Minute) {
+		v, err := s.views.Sessions.Get(util.GetBucketKey(b))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	// FIXME: check for max time range
+		sa := api.SessionAggregates{}
+		if v != nil {
+			sa = v.(api.SessionAggregates)
+		}
 
-	if !values.Has("action") {
-		http.Error(w, "required parameter 'action' is missing", http.StatusBadRequest)
+		var avg float64
+		if sa.Count > 0 {
+			avg = sa.TotalLength.Seconds() / float64(sa.Count)
+		}
+
+		rows = append(rows, api.SessionAggregatesRow{
+			Bucket:    api.BucketTime(b),
+			Count:     api.AggregateValue(sa.Count),
+			AvgLength: strconv.FormatFloat(avg, 'f', 3, 64),
+		})
+	}
+
+	payload, err := json.Marshal(api.SessionAggregatesResponse{Rows: rows})
+	if err != nil {
+		klog.Errorf("can't marshall session aggregates response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// currencyRegexp matches ISO 4217 currency codes.
+var currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// aggregatesQuery holds the parameters of an aggregates query which apply to every bucket.
+type aggregatesQuery struct {
+	action     api.Action
+	aggregates []api.Aggregate
+	columns    []api.AggregateColumn
+	filters    []string
+	dimensions map[api.AggregateColumn]string
+	// currency selects the sums of prices in the given original currency instead of the base currency.
+	currency string
+}
+
+func (s *server) parseAggregatesQuery(values url.Values) (aggregatesQuery, error) {
+	q := aggregatesQuery{
+		dimensions: make(map[api.AggregateColumn]string),
+	}
+
+	if !values.Has("action") {
+		return q, errors.New("required parameter 'action' is missing")
+	}
 	action, err := api.ParseAction(values.Get("action"))
 	if err != nil {
-		http.Error(w, fmt.Errorf("required parameter 'action' is invalid: %v", err).Error(), http.StatusBadRequest)
-		return
+		return q, fmt.Errorf("required parameter 'action' is invalid: %v", err)
 	}
+	if action == api.REFUND {
+		return q, errors.New("REFUNDs aren't aggregated, they're subtracted from BUY aggregates")
+	}
+	q.action = action
 
 	if !values.Has("aggregates") {
-		http.Error(w, "required parameter 'action' is missing", http.StatusBadRequest)
-		return
+		return q, errors.New("required parameter 'aggregates' is missing")
 	}
 
-	aggregates := make([]api.Aggregate, 0)
+	q.aggregates = make([]api.Aggregate, 0)
 	for _, s := range values["aggregates"] {
 		a, err := api.ParseAggregate(s)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			return q, err
 		}
-		aggregates = append(aggregates, a)
+		q.aggregates = append(q.aggregates, a)
 	}
 
-	origin := values.Get("origin")
-	brand_id := values.Get("brand_id")
-	category_id := values.Get("category_id")
+	q.columns = []api.AggregateColumn{api.BUCKET, api.ACTION}
+	q.filters = make([]string, 0)
+	enabled := make(map[api.AggregateColumn]bool, len(s.config.Dimensions))
+	for _, d := range s.config.Dimensions {
+		enabled[d.Column] = true
+	}
+	filtered := make([]api.Dimension, 0)
+	for _, d := range api.Dimensions {
+		v := values.Get(string(d.Column))
+		if len(v) == 0 {
+			continue
+		}
+		if !enabled[d.Column] {
+			return q, fmt.Errorf("optional parameter '%s' isn't aggregated by this deployment", d.Column)
+		}
+		filtered = append(filtered, d)
+
+		if d.Validate != nil {
+			err = d.Validate(v)
+			if err != nil {
+				return q, fmt.Errorf("optional parameter '%s' is invalid: %v", d.Column, err)
+			}
+		}
 
-	columns := []api.AggregateColumn{api.BUCKET, api.ACTION}
-	if len(origin) > 0 {
-		columns = append(columns, api.ORIGIN)
+		q.columns = append(q.columns, d.Column)
+		q.filters = append(q.filters, util.FilterValue(d.Column, v))
+		q.dimensions[d.Column] = v
+	}
+	if !api.Combinable(filtered) {
+		return q, fmt.Errorf("parameters %v can't be combined, at most one dimension of each group can be given", q.columns[2:])
 	}
-	if len(brand_id) > 0 {
-		columns = append(columns, api.BRAND_ID)
+	// Aggregates of parent categories are only kept for the category alone and combined with the brand.
+	if c, ok := q.dimensions[api.CategoryDimension.Column]; ok && s.config.Categories != nil &&
+		s.config.Categories.Get().HasChildren(c) && !category.RolledUp(q.columns[2:]) {
+		return q, fmt.Errorf("parent category %q can only be combined with the parameter '%s'", c, api.BrandDimension.Column)
 	}
-	if len(category_id) > 0 {
-		columns = append(columns, api.CATEGORY_ID)
+	if values.Has("currency") {
+		q.currency = values.Get("currency")
+		if !currencyRegexp.MatchString(q.currency) {
+			return q, fmt.Errorf("optional parameter 'currency' is invalid: %q is not a currency code", q.currency)
+		}
 	}
-	for _, a := range aggregates {
-		columns = append(columns, api.AggregateToAggregateColumn(a))
+	for _, a := range q.aggregates {
+		q.columns = append(q.columns, api.AggregateToAggregateColumn(a))
 	}
 
-	rows := make([]api.AggregateRow, 0)
-	for b := lowerBound; b.Before(upperBound); b = b.Add(time.Minute) {
-		hash := util.GetAggregateHash(b, action, origin, brand_id, category_id)
+	return q, nil
+}
+
+func (q aggregatesQuery) hash(bucket time.Time) string {
+	return util.GetAggregateHash(bucket, q.action, q.filters...)
+}
+
+// legacyHash returns the key the aggregate had before filter values were qualified with their columns.
+// Queries by dimensions added later have no such key. Neither do queries by none, as their key is the same in both formats.
+func (q aggregatesQuery) legacyHash(bucket time.Time) (string, bool) {
+	if len(q.dimensions) == 0 {
+		return "", false
+	}
+	for c := range q.dimensions {
+		if c != api.OriginDimension.Column && c != api.BrandDimension.Column && c != api.CategoryDimension.Column {
+			return "", false
+		}
+	}
+
+	return util.GetLegacyAggregateHash(bucket, q.action, q.dimensions[api.OriginDimension.Column],
+		q.dimensions[api.BrandDimension.Column], q.dimensions[api.CategoryDimension.Column]), true
+}
+
+func (q aggregatesQuery) row(bucket time.Time, ua api.UserAggregates) api.AggregateRow {
+	sumPrice, lateSumPrice := ua.SumPrice, ua.LateSumPrice
+	if len(q.currency) > 0 {
+		sumPrice, lateSumPrice = ua.SumPriceByCurrency[q.currency], ua.LateSumPriceByCurrency[q.currency]
+	}
+
+	return api.AggregateRow{
+		Bucket:       api.BucketTime(bucket),
+		Action:       q.action,
+		Dimensions:   q.dimensions,
+		Count:        api.AggregateValue(ua.Count),
+		SumPrice:     api.AggregateValue(sumPrice),
+		LateCount:    api.AggregateValue(ua.LateCount),
+		LateSumPrice: api.AggregateValue(lateSumPrice),
+		Completeness: api.PARTIAL,
+	}
+}
+
+func getUserAggregates(view *goka.View, key string) (api.UserAggregates, error) {
+	v, err := view.Get(key)
+	if err != nil {
+		return api.UserAggregates{}, err
+	}
+
+	ua := api.UserAggregates{}
+	if v != nil {
+		ua = v.(api.UserAggregates)
+	}
+
+	return ua, nil
+}
+
+func (s *server) getAggregateRow(view *goka.View, q aggregatesQuery, bucket time.Time) (api.AggregateRow, error) {
+	ua, err := getUserAggregates(view, q.hash(bucket))
+	if err != nil {
+		return api.AggregateRow{}, err
+	}
+
+	row := q.row(bucket, ua)
 
-		v, err := s.view.Get(hash)
+	// Buckets aggregated while the key format changed are split between both keys, so they're summed until the
+	// aggregates written under the legacy keys fall out of retention.
+	if legacy, ok := q.legacyHash(bucket); ok {
+		ua, err = getUserAggregates(view, legacy)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return api.AggregateRow{}, err
+		}
+
+		lr := q.row(bucket, ua)
+		row.Count += lr.Count
+		row.SumPrice += lr.SumPrice
+		row.LateCount += lr.LateCount
+		row.LateSumPrice += lr.LateSumPrice
+	}
+
+	return row, nil
+}
+
+func (s *server) AggregatesPostHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	if !values.Has("time_range") {
+		http.Error(w, "required parameter 'time_range' is missing", http.StatusBadRequest)
+		return
+	}
+
+	timeRange := values.Get("time_range")
+	lowerBound, upperBound, err := api.ParseTimeRange(timeRange)
+	if err != nil {
+		http.Error(w, fmt.Errorf("can't parse time range: %w", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	// FIXME: validate time ranges
+
+	// FIXME: check for max time range
+
+	q, err := s.parseAggregatesQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var completeness bool
+	if values.Has("completeness") {
+		completeness, err = strconv.ParseBool(values.Get("completeness"))
+		if err != nil {
+			http.Error(w, "optional parameter 'completeness' is invalid", http.StatusBadRequest)
 			return
 		}
+	}
 
-		ua := api.UserAggregates{}
-		if v != nil {
-			ua = v.(api.UserAggregates)
+	var attributed bool
+	if values.Has("attributed") {
+		attributed, err = strconv.ParseBool(values.Get("attributed"))
+		if err != nil {
+			http.Error(w, "optional parameter 'attributed' is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Attributed aggregates credit BUYs to the origin of the last VIEW of the product.
+	view, group := s.views.Aggregates, kafka.SinkGroup
+	if attributed {
+		if q.action != api.BUY {
+			http.Error(w, "only BUY aggregates can be attributed", http.StatusBadRequest)
+			return
+		}
+		view, group = s.views.Attributed, kafka.AttributionSinkGroup
+	}
+
+	columns := q.columns
+	var watermark time.Time
+	var caughtUp bool
+	if completeness {
+		columns = append(columns, api.COMPLETENESS)
+
+		ws, err := s.getWatermarks(group)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		watermark = ws.Min()
+		caughtUp = viewCaughtUp(r.Context(), view)
+	}
 
-		r := api.AggregateRow{
-			Bucket:     api.BucketTime(b),
-			Action:     action,
-			Origin:     origin,
-			BrandID:    brand_id,
-			CategoryID: category_id,
-			Count:      api.AggregateValue(ua.Count),
-			SumPrice:   api.AggregateValue(ua.SumPrice),
+	rows := make([]api.AggregateRow, 0)
+	for b := lowerBound; b.Before(upperBound); b = b.Add(time.Minute) {
+		row, err := s.getAggregateRow(view, q, b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		rows = append(rows, r)
+		row.Completeness = bucketCompleteness(b, watermark, caughtUp)
+		rows = append(rows, row)
 	}
 
 	ar := api.AggregateResponse{
@@ -259,42 +706,737 @@ func (s *server) AggregatesPostHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(payload)
 }
 
-func (s *server) HealthzHandler(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
-}
+func (s *server) AggregatesForecastGetHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
 
-func (s *server) ReadyzHandler(w http.ResponseWriter, _ *http.Request) {
-	var err error
+	q, err := s.parseAggregatesQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, a := range q.aggregates {
+		if a != api.AGGREGATE_COUNT && a != api.AGGREGATE_SUM_PRICE {
+			http.Error(w, "only COUNT and SUM_PRICE aggregates can be forecast", http.StatusBadRequest)
+			return
+		}
+	}
 
-	if !s.upStore.Client.IsConnected() {
-		err = errors.New("readyz probe: can't connect with database")
-		klog.Error(err)
+	horizon := ForecastDefaultHorizon
+	if values.Has("horizon") {
+		horizon, err = time.ParseDuration(values.Get("horizon"))
+		if err != nil || horizon <= 0 || horizon > ForecastMaxHorizon || horizon%time.Minute != 0 {
+			http.Error(w, fmt.Sprintf("optional parameter 'horizon' has to be a multiple of a minute up to %s", ForecastMaxHorizon), http.StatusBadRequest)
+			return
+		}
+	}
+
+	confidence := ForecastDefaultConfidence
+	if values.Has("confidence") {
+		confidence, err = strconv.ParseFloat(values.Get("confidence"), 64)
+		if err != nil || confidence <= 0 || confidence >= 1 {
+			http.Error(w, "optional parameter 'confidence' has to be within (0, 1)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ws, err := s.getWatermarks(kafka.SinkGroup)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if ws.Min().IsZero() {
+		http.Error(w, "collector hasn't reported a watermark yet", http.StatusServiceUnavailable)
+		return
+	}
+	// The model is fitted on the closed buckets and forecasts the ones following them.
+	end := ws.Min().Truncate(time.Minute)
 
-	w.WriteHeader(http.StatusOK)
-}
+	history := make([]api.AggregateRow, 0, int(ForecastHistory/time.Minute))
+	for b := end.Add(-ForecastHistory); b.Before(end); b = b.Add(time.Minute) {
+		row, err := s.getAggregateRow(s.views.Aggregates, q, b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		history = append(history, row)
+	}
 
-func NewHTTPServer(addr string, userProfileStore *aerospike.AerospikeStore[api.UserProfile], emitter *goka.Emitter, view *goka.View) *http.Server {
-	s := &server{
-		upStore: userProfileStore,
-		emitter: emitter,
-		view:    view,
+	steps := int(horizon / time.Minute)
+	rows := make([]api.AggregateRow, steps)
+	for h := range rows {
+		rows[h] = q.row(end.Add(time.Duration(h)*time.Minute), api.UserAggregates{})
+		rows[h].Bounds = make(map[api.AggregateColumn]api.AggregateValue)
 	}
 
-	r := mux.NewRouter()
+	for _, a := range q.aggregates {
+		c := api.AggregateToAggregateColumn(a)
 
-	r.HandleFunc("/user_tags", s.UserTagsPostHandler).
-		Methods(http.MethodPost).
-		Headers("Content-Type", "application/json")
+		ys := make([]float64, len(history))
+		for i, row := range history {
+			ys[i] = float64(row.Count)
+			if a == api.AGGREGATE_SUM_PRICE {
+				ys[i] = float64(row.SumPrice)
+			}
+		}
 
-	r.HandleFunc("/user_profiles/{cookie}", s.UserProfilesPostHandler).
+		m, err := forecast.Fit(ys, ForecastSeason)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for h := range rows {
+			v := nonNegativeAggregateValue(m.Forecast(h + 1))
+			lower, upper := m.Interval(h+1, confidence)
+
+			if a == api.AGGREGATE_SUM_PRICE {
+				rows[h].SumPrice = v
+			} else {
+				rows[h].Count = v
+			}
+			rows[h].Bounds[api.LowerColumn(c)] = nonNegativeAggregateValue(lower)
+			rows[h].Bounds[api.UpperColumn(c)] = nonNegativeAggregateValue(upper)
+		}
+	}
+
+	columns := make([]api.AggregateColumn, 0, len(q.columns)+2*len(q.aggregates))
+	for _, c := range q.columns {
+		columns = append(columns, c)
+		if c == api.COUNT || c == api.SUM_PRICE {
+			columns = append(columns, api.LowerColumn(c), api.UpperColumn(c))
+		}
+	}
+
+	payload, err := json.Marshal(api.AggregateResponse{
+		Columns: columns,
+		Rows:    rows,
+	})
+	if err != nil {
+		klog.Errorf("can't marshall aggregate forecast response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (s *server) TopGetHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	if !values.Has("time_range") {
+		http.Error(w, "required parameter 'time_range' is missing", http.StatusBadRequest)
+		return
+	}
+
+	lowerBound, upperBound, err := api.ParseTimeRange(values.Get("time_range"))
+	if err != nil {
+		http.Error(w, fmt.Errorf("can't parse time range: %w", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !values.Has("action") {
+		http.Error(w, "required parameter 'action' is missing", http.StatusBadRequest)
+		return
+	}
+	action, err := api.ParseAction(values.Get("action"))
+	if err != nil {
+		http.Error(w, fmt.Errorf("required parameter 'action' is invalid: %v", err).Error(), http.StatusBadRequest)
+		return
+	}
+	if action == api.REFUND {
+		http.Error(w, "REFUNDs aren't aggregated, they're subtracted from BUY aggregates", http.StatusBadRequest)
+		return
+	}
+
+	if !values.Has("dimension") {
+		http.Error(w, "required parameter 'dimension' is missing", http.StatusBadRequest)
+		return
+	}
+	dimension, err := api.ParseTopDimension(values.Get("dimension"))
+	if err != nil {
+		http.Error(w, fmt.Errorf("required parameter 'dimension' is invalid: %v", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	metric := api.TOP_COUNT
+	if values.Has("metric") {
+		metric, err = api.ParseTopMetric(values.Get("metric"))
+		if err != nil {
+			http.Error(w, fmt.Errorf("optional parameter 'metric' is invalid: %v", err).Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	k := TopDefaultK
+	if values.Has("k") {
+		k, err = strconv.Atoi(values.Get("k"))
+		if err != nil || k <= 0 {
+			http.Error(w, "optional parameter 'k' is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	key := api.TopKey(dimension.Column, metric)
+	var merged *topk.Sketch
+	for b := lowerBound.Truncate(time.Minute); b.Before(upperBound); b = b.Add(time.Minute) {
+		v, err := s.views.Aggregates.Get(util.GetAggregateHash(b, action))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if v == nil {
+			continue
+		}
+
+		sketch, ok := v.(api.UserAggregates).TopK[key]
+		if !ok {
+			continue
+		}
+
+		if merged == nil {
+			merged = topk.NewSketch(sketch.Capacity)
+		}
+		merged.Merge(*sketch)
+	}
+
+	tr := api.TopResponse{
+		Action:    action,
+		Dimension: dimension.Column,
+		Metric:    metric,
+		Items:     make([]api.TopItem, 0),
+	}
+	if merged != nil {
+		for _, c := range merged.Top(k) {
+			tr.Items = append(tr.Items, api.TopItem{
+				Value:    c.Item,
+				Total:    api.AggregateValue(c.Count),
+				MaxError: api.AggregateValue(c.Error),
+			})
+		}
+	}
+
+	payload, err := json.Marshal(tr)
+	if err != nil {
+		klog.Errorf("can't marshall top response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (s *server) FunnelGetHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	if !values.Has("time_range") {
+		http.Error(w, "required parameter 'time_range' is missing", http.StatusBadRequest)
+		return
+	}
+
+	lowerBound, upperBound, err := api.ParseTimeRange(values.Get("time_range"))
+	if err != nil {
+		http.Error(w, fmt.Errorf("can't parse time range: %w", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !values.Has("window") {
+		http.Error(w, "required parameter 'window' is missing", http.StatusBadRequest)
+		return
+	}
+	window, err := time.ParseDuration(values.Get("window"))
+	if err != nil || window <= 0 || window%time.Hour != 0 {
+		http.Error(w, "required parameter 'window' has to be a positive number of hours", http.StatusBadRequest)
+		return
+	}
+
+	filters := make([]string, 0)
+	dimensions := make(map[api.AggregateColumn]string)
+	for _, d := range api.FunnelDimensions {
+		v := values.Get(string(d.Column))
+		if len(v) == 0 {
+			continue
+		}
+
+		filters = append(filters, util.FilterValue(d.Column, v))
+		dimensions[d.Column] = v
+	}
+
+	rows := make([]api.FunnelRow, 0)
+	for b := lowerBound; b.Before(upperBound); b = b.Add(time.Minute) {
+		v, err := s.views.Funnel.Get(util.GetAggregateHash(b, api.VIEW, filters...))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		fa := api.FunnelAggregates{}
+		if v != nil {
+			fa = v.(api.FunnelAggregates)
+		}
+
+		// Conversions are only tracked for as long as the forwarder was configured to when the bucket was viewed.
+		if fa.MaxWindow > 0 && window > fa.MaxWindow {
+			http.Error(w, fmt.Sprintf("required parameter 'window' can't exceed %v", fa.MaxWindow), http.StatusBadRequest)
+			return
+		}
+
+		buyers := fa.ConvertedWithin(window)
+		var rate float64
+		if fa.Viewers > 0 {
+			rate = float64(buyers) / float64(fa.Viewers)
+		}
+
+		rows = append(rows, api.FunnelRow{
+			Bucket:         api.BucketTime(b),
+			Viewers:        api.AggregateValue(fa.Viewers),
+			Buyers:         api.AggregateValue(buyers),
+			ConversionRate: strconv.FormatFloat(rate, 'f', 4, 64),
+		})
+	}
+
+	fr := api.FunnelResponse{
+		Window:  window.String(),
+		Filters: dimensions,
+		Rows:    rows,
+	}
+
+	payload, err := json.Marshal(fr)
+	if err != nil {
+		klog.Errorf("can't marshall funnel response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (s *server) CohortsGetHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	values := r.URL.Query()
+
+	kind := api.FIRST_SEEN
+	if values.Has("by") {
+		kind, err = api.ParseCohortKind(values.Get("by"))
+		if err != nil {
+			http.Error(w, fmt.Errorf("optional parameter 'by' is invalid: %v", err).Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !values.Has("action") {
+		http.Error(w, "required parameter 'action' is missing", http.StatusBadRequest)
+		return
+	}
+	action, err := api.ParseAction(values.Get("action"))
+	if err != nil {
+		http.Error(w, fmt.Errorf("required parameter 'action' is invalid: %v", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	periods := CohortsDefaultPeriods
+	if values.Has("periods") {
+		periods, err = strconv.Atoi(values.Get("periods"))
+		if err != nil || periods <= 0 || periods > api.CohortMaxPeriods {
+			http.Error(w, fmt.Sprintf("optional parameter 'periods' has to be within [1, %d]", api.CohortMaxPeriods), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Cohort keys of a kind share a prefix and first seen cohorts sort by their days.
+	start, limit := api.CohortKey(kind, ""), string(kind)+">"
+	if values.Has("time_range") {
+		if kind != api.FIRST_SEEN {
+			http.Error(w, "optional parameter 'time_range' only applies to first seen cohorts", http.StatusBadRequest)
+			return
+		}
+
+		lowerBound, upperBound, err := api.ParseTimeRange(values.Get("time_range"))
+		if err != nil {
+			http.Error(w, fmt.Errorf("can't parse time range: %w", err).Error(), http.StatusBadRequest)
+			return
+		}
+		start = api.CohortKey(kind, lowerBound.Truncate(api.CohortPeriod).Format("2006-01-02"))
+		limit = api.CohortKey(kind, upperBound.Add(api.CohortPeriod-1).Truncate(api.CohortPeriod).Format("2006-01-02"))
+	}
+
+	it, err := s.views.Cohorts.IteratorWithRange(start, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer it.Release()
+
+	rows := make([]api.CohortRow, 0)
+	for it.Next() {
+		v, err := it.Value()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if v == nil {
+			continue
+		}
+		ca := v.(api.CohortAggregates)
+
+		row := api.CohortRow{
+			Cohort:    strings.TrimPrefix(it.Key(), api.CohortKey(kind, "")),
+			Size:      ca.Size,
+			Active:    make([]int64, periods),
+			Retention: make([]float64, periods),
+		}
+		copy(row.Active, ca.Active[action.String()])
+		for i, n := range row.Active {
+			if ca.Size > 0 {
+				row.Retention[i] = float64(n) / float64(ca.Size)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err := it.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Cohort < rows[j].Cohort
+	})
+
+	payload, err := json.Marshal(api.CohortsResponse{
+		By:      kind,
+		Action:  action,
+		Cohorts: rows,
+	})
+	if err != nil {
+		klog.Errorf("can't marshall cohorts response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (s *server) AnomaliesGetHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	if !values.Has("time_range") {
+		http.Error(w, "required parameter 'time_range' is missing", http.StatusBadRequest)
+		return
+	}
+
+	lowerBound, upperBound, err := api.ParseTimeRange(values.Get("time_range"))
+	if err != nil {
+		http.Error(w, fmt.Errorf("can't parse time range: %w", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	series := values.Get("series")
+
+	anomalies := make([]api.Anomaly, 0)
+	for b := lowerBound.Truncate(time.Minute); b.Before(upperBound); b = b.Add(time.Minute) {
+		v, err := s.views.Anomalies.Get(util.GetBucketKey(b))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if v == nil {
+			continue
+		}
+
+		for _, a := range v.(api.Anomalies).Anomalies {
+			if len(series) == 0 || a.Series == series {
+				anomalies = append(anomalies, a)
+			}
+		}
+	}
+
+	payload, err := json.Marshal(api.AnomaliesResponse{Anomalies: anomalies})
+	if err != nil {
+		klog.Errorf("can't marshall anomalies response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// getRelated merges the co-occurrences of the given products with the products action was taken on.
+// The merged sketch holds all of their counters, so that leaving the products themselves out doesn't shorten the result.
+func (s *server) getRelated(productIDs []string, action api.Action) (*topk.Sketch, error) {
+	merged := topk.NewSketch(0)
+	for _, id := range productIDs {
+		v, err := s.views.Cooccurrence.Get(api.CooccurrenceKey(action, id))
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+
+		co := v.(api.Cooccurrences)
+		sketch := co.Sketch()
+		merged.Capacity += sketch.Capacity
+		merged.Merge(*sketch)
+	}
+
+	merged.Counters = FilterSlice(merged.Counters, func(c topk.Counter) bool {
+		for _, id := range productIDs {
+			if c.Item == id {
+				return false
+			}
+		}
+		return true
+	})
+
+	return merged, nil
+}
+
+func parseRecommendationsLimit(values url.Values) (int, error) {
+	if !values.Has("limit") {
+		return RecommendationsDefaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err != nil || limit <= 0 {
+		return 0, errors.New("optional parameter 'limit' is invalid")
+	}
+
+	return limit, nil
+}
+
+func recommendations(related *topk.Sketch, limit int) []api.Recommendation {
+	res := make([]api.Recommendation, 0)
+	for _, c := range related.Top(limit) {
+		res = append(res, api.Recommendation{
+			ProductID: c.Item,
+			Score:     api.AggregateValue(c.Count),
+		})
+	}
+
+	return res
+}
+
+// writeRecommendations responds with the products viewed and bought alongside the given products.
+func (s *server) writeRecommendations(w http.ResponseWriter, rr api.RecommendationsResponse, productIDs []string, limit int) {
+	viewed, err := s.getRelated(productIDs, api.VIEW)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bought, err := s.getRelated(productIDs, api.BUY)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rr.AlsoViewed = recommendations(viewed, limit)
+	rr.AlsoBought = recommendations(bought, limit)
+
+	payload, err := json.Marshal(rr)
+	if err != nil {
+		klog.Errorf("can't marshall recommendations response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (s *server) RecommendationsGetHandler(w http.ResponseWriter, r *http.Request) {
+	productID := mux.Vars(r)["product_id"]
+	if _, err := strconv.ParseUint(productID, 10, 64); err != nil {
+		http.Error(w, fmt.Errorf("product id is invalid: %v", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := parseRecommendationsLimit(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.writeRecommendations(w, api.RecommendationsResponse{ProductID: productID}, []string{productID}, limit)
+}
+
+func (s *server) UserRecommendationsGetHandler(w http.ResponseWriter, r *http.Request) {
+	cookie := mux.Vars(r)["cookie"]
+
+	limit, err := parseRecommendationsLimit(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	up := api.UserProfile{}
+	err = s.upStore.Get(cookie, &up, false)
+	if err != nil {
+		klog.ErrorS(err, "can't get user profile", "cookie", cookie)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	seen := make(map[string]bool)
+	productIDs := make([]string, 0, RecommendationsSeedLimit)
+	for _, ut := range up.Views {
+		if len(productIDs) >= RecommendationsSeedLimit {
+			break
+		}
+
+		id := strconv.FormatUint(ut.Product.ProductID, 10)
+		if !seen[id] {
+			seen[id] = true
+			productIDs = append(productIDs, id)
+		}
+	}
+
+	s.writeRecommendations(w, api.RecommendationsResponse{Cookie: cookie}, productIDs, limit)
+}
+
+func (s *server) getWatermarks(group goka.Group) (api.Watermarks, error) {
+	v, err := s.views.Watermarks.Get(string(group))
+	if err != nil {
+		return api.Watermarks{}, fmt.Errorf("can't get watermarks of %s: %w", group, err)
+	}
+
+	ws := api.Watermarks{}
+	if v != nil {
+		ws = v.(api.Watermarks)
+	}
+
+	return ws, nil
+}
+
+func (s *server) WatermarksGetHandler(w http.ResponseWriter, _ *http.Request) {
+	res := make(map[string]api.WatermarkResponse)
+	for _, g := range []goka.Group{kafka.ForwarderGroup, kafka.SinkGroup} {
+		ws, err := s.getWatermarks(g)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		res[string(g)] = api.WatermarkResponse{
+			Watermark:  ws.Min(),
+			Partitions: ws.Partitions,
+		}
+	}
+
+	payload, err := json.Marshal(res)
+	if err != nil {
+		klog.Errorf("can't marshall watermarks response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (s *server) HealthzHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *server) ReadyzHandler(w http.ResponseWriter, _ *http.Request) {
+	var err error
+
+	if !s.upStore.Client.IsConnected() {
+		err = errors.New("readyz probe: can't connect with database")
+		klog.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func NewHTTPServer(addr string, userProfileStore *aerospike.AerospikeStore[api.UserProfile], identities *identity.Graph, emitters Emitters, views Views, feed *ChangeFeed, config Config) *http.Server {
+	s := &server{
+		upStore:    userProfileStore,
+		identities: identities,
+		emitters:   emitters,
+		views:      views,
+		feed:       feed,
+		config:     config,
+	}
+
+	r := mux.NewRouter()
+
+	r.HandleFunc("/user_tags", s.UserTagsPostHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+
+	r.HandleFunc("/user_profiles/{cookie}", s.UserProfilesPostHandler).
 		Methods(http.MethodPost)
 
+	r.HandleFunc("/identities/link", s.IdentitiesLinkPostHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+
+	r.HandleFunc("/identities/{cookie}", s.IdentitiesGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/user_profiles/{cookie}/sessions", s.UserSessionsGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/features/{cookie}", s.FeaturesGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/user_profiles/{cookie}/interests", s.UserInterestsGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/user_profiles/{cookie}/segments", s.UserSegmentsGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/segments/{id}/members", s.SegmentMembersGetHandler).
+		Methods(http.MethodGet)
+
 	r.HandleFunc("/aggregates", s.AggregatesPostHandler).
 		Methods(http.MethodPost)
 
+	r.HandleFunc("/aggregates/forecast", s.AggregatesForecastGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/aggregates/stream", s.AggregatesStreamGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/sessions/aggregates", s.SessionAggregatesGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/top", s.TopGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/funnel", s.FunnelGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/catalog/products/{product_id}", s.CatalogProductPutHandler).
+		Methods(http.MethodPut).
+		Headers("Content-Type", "application/json")
+
+	r.HandleFunc("/paths", s.PathsGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/cohorts", s.CohortsGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/anomalies", s.AnomaliesGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/recommendations/{product_id}", s.RecommendationsGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/user_profiles/{cookie}/recommendations", s.UserRecommendationsGetHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/watermarks", s.WatermarksGetHandler).
+		Methods(http.MethodGet)
+
 	r.HandleFunc("/healthz", s.HealthzHandler).
 		Methods(http.MethodGet)
 
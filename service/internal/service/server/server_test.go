@@ -0,0 +1,201 @@
+package server
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/currency"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/reload"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetractBuy(t *testing.T) {
+	purchaseTime := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	buy := api.UserTag{
+		Time:    purchaseTime,
+		Cookie:  "c",
+		Country: "PL",
+		Device:  api.PC,
+		Action:  api.BUY,
+		Origin:  "X",
+		Product: api.Product{ProductID: 1, BrandID: "Nike", CategoryID: "SHOES", Price: 1000},
+	}
+	other := buy
+	other.Product.ProductID = 2
+
+	ts := []struct {
+		name           string
+		buys           []api.UserTag
+		refund         api.UserTag
+		expectedBuys   []api.UserTag
+		expectedRefund api.UserTag
+		expected       bool
+	}{
+		{
+			name: "Matching BUY is removed and completes the refund",
+			buys: []api.UserTag{other, buy},
+			refund: api.UserTag{
+				Time:         purchaseTime.Add(time.Hour),
+				Cookie:       "c",
+				Action:       api.REFUND,
+				Product:      api.Product{ProductID: 1},
+				PurchaseTime: &purchaseTime,
+			},
+			expectedBuys: []api.UserTag{other},
+			expectedRefund: api.UserTag{
+				Time:         purchaseTime.Add(time.Hour),
+				Cookie:       "c",
+				Country:      "PL",
+				Device:       api.PC,
+				Action:       api.REFUND,
+				Origin:       "X",
+				Product:      buy.Product,
+				PurchaseTime: &purchaseTime,
+			},
+			expected: true,
+		},
+		{
+			name: "Refund of a missing BUY is rejected",
+			buys: []api.UserTag{other},
+			refund: api.UserTag{
+				Time:         purchaseTime.Add(time.Hour),
+				Cookie:       "c",
+				Action:       api.REFUND,
+				Product:      api.Product{ProductID: 1},
+				PurchaseTime: &purchaseTime,
+			},
+			expectedBuys: []api.UserTag{other},
+			expectedRefund: api.UserTag{
+				Time:         purchaseTime.Add(time.Hour),
+				Cookie:       "c",
+				Action:       api.REFUND,
+				Product:      api.Product{ProductID: 1},
+				PurchaseTime: &purchaseTime,
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			up := api.UserProfile{Buys: append([]api.UserTag{}, test.buys...)}
+			refund := test.refund
+
+			res := retractBuy(&up, &refund)
+			if test.expected != res {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+			if !reflect.DeepEqual(test.expectedBuys, up.Buys) {
+				t.Errorf("expected and computed results differ: %s", cmp.Diff(test.expectedBuys, up.Buys))
+			}
+			if !reflect.DeepEqual(test.expectedRefund, refund) {
+				t.Errorf("expected and computed results differ: %s", cmp.Diff(test.expectedRefund, refund))
+			}
+		})
+	}
+}
+
+func TestBuyEvicted(t *testing.T) {
+	base := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	buys := func(n int) []api.UserTag {
+		res := make([]api.UserTag, 0, n)
+		for i := n - 1; i >= 0; i-- {
+			res = append(res, api.UserTag{Time: base.Add(time.Duration(i) * time.Minute), Action: api.BUY})
+		}
+		return res
+	}
+
+	ts := []struct {
+		name     string
+		buys     []api.UserTag
+		t        time.Time
+		expected bool
+	}{
+		{
+			name:     "BUY older than the ones of a full profile has left it",
+			buys:     buys(UserTagPerActionLimit),
+			t:        base.Add(-time.Minute),
+			expected: true,
+		},
+		{
+			name:     "BUY within the ones of a full profile is still kept",
+			buys:     buys(UserTagPerActionLimit),
+			t:        base.Add(time.Minute),
+			expected: false,
+		},
+		{
+			name:     "BUY older than the ones of a profile which isn't full is still kept",
+			buys:     buys(UserTagPerActionLimit - 1),
+			t:        base.Add(-time.Minute),
+			expected: false,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res := buyEvicted(&api.UserProfile{Buys: test.buys}, test.t)
+			if test.expected != res {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestConvertPrice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	err := os.WriteFile(path, []byte(`{"base": "USD", "currencies": [{"currency": "USD", "exponent": 2, "rate": 1}, {"currency": "PLN", "exponent": 2, "rate": 0.25}]}`), 0644)
+	if err != nil {
+		t.Fatalf("can't write exchange rates: %v", err)
+	}
+	rates, err := reload.NewFile(path, currency.Parse)
+	if err != nil {
+		t.Fatalf("can't load exchange rates: %v", err)
+	}
+
+	price := func(p int64) *int64 {
+		return &p
+	}
+
+	ts := []struct {
+		name     string
+		rates    *reload.File[*currency.Rates]
+		product  api.Product
+		expected *int64
+	}{
+		{
+			name:     "Prices aren't converted without exchange rates",
+			product:  api.Product{Price: 1000, Currency: "PLN"},
+			expected: nil,
+		},
+		{
+			name:     "Price is converted to the base currency",
+			rates:    rates,
+			product:  api.Product{Price: 1000, Currency: "PLN"},
+			expected: price(250),
+		},
+		{
+			name:     "Price in a currency without an exchange rate adds nothing in the base currency",
+			rates:    rates,
+			product:  api.Product{Price: 1000, Currency: "EUR"},
+			expected: price(0),
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			s := &server{config: Config{ExchangeRates: test.rates}}
+			ut := api.UserTag{Action: api.BUY, Product: test.product}
+
+			s.convertPrice(&ut)
+			if !reflect.DeepEqual(test.expected, ut.Product.BasePrice) {
+				t.Errorf("expected and computed results differ: %s", cmp.Diff(test.expected, ut.Product.BasePrice))
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lovoo/goka"
+	"github.com/lovoo/goka/storage"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"k8s.io/klog/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AggregateChange is an update of a collector table entry.
+type AggregateChange struct {
+	Key        string
+	Aggregates api.UserAggregates
+}
+
+// ChangeFeed fans the updates of the collector table out to the subscribed streams.
+type ChangeFeed struct {
+	mu          sync.Mutex
+	subscribers map[chan AggregateChange]struct{}
+	codec       api.UserAggregatesCodec
+}
+
+func NewChangeFeed() *ChangeFeed {
+	return &ChangeFeed{
+		subscribers: make(map[chan AggregateChange]struct{}),
+	}
+}
+
+// Update is a view callback which stores the update and publishes it to the subscribers.
+// Subscribers which can't keep up miss the update rather than block the view.
+func (cf *ChangeFeed) Update(ctx goka.UpdateContext, s storage.Storage, key string, value []byte) error {
+	err := goka.DefaultUpdate(ctx, s, key, value)
+	if err != nil {
+		return err
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if len(cf.subscribers) == 0 {
+		return nil
+	}
+
+	v, err := cf.codec.Decode(value)
+	if err != nil {
+		klog.ErrorS(err, "can't decode aggregates update", "key", key)
+		return nil
+	}
+
+	c := AggregateChange{Key: key, Aggregates: v.(api.UserAggregates)}
+	for ch := range cf.subscribers {
+		select {
+		case ch <- c:
+		default:
+			klog.V(2).InfoS("dropping aggregates update for a slow subscriber", "key", key)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe registers a new subscriber. The returned function unsubscribes it.
+func (cf *ChangeFeed) Subscribe() (<-chan AggregateChange, func()) {
+	ch := make(chan AggregateChange, AggregatesStreamBufferSize)
+
+	cf.mu.Lock()
+	cf.subscribers[ch] = struct{}{}
+	cf.mu.Unlock()
+
+	return ch, func() {
+		cf.mu.Lock()
+		delete(cf.subscribers, ch)
+		cf.mu.Unlock()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, ar api.AggregateResponse) error {
+	payload, err := json.Marshal(ar)
+	if err != nil {
+		return fmt.Errorf("can't marshall aggregate response: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	if err != nil {
+		return fmt.Errorf("can't write event: %w", err)
+	}
+
+	w.(http.Flusher).Flush()
+
+	return nil
+}
+
+// AggregatesStreamGetHandler pushes the rows of the open buckets as the collector updates them.
+// Buckets are opened as the wall clock enters them and closed with a final update once they're behind the collector's watermark.
+func (s *server) AggregatesStreamGetHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := s.parseAggregatesQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	columns := append(q.columns, api.COMPLETENESS)
+
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	changes, unsubscribe := s.feed.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	// open holds the buckets which haven't been closed yet keyed by their hashes.
+	open := make(map[string]time.Time)
+	next := time.Now().UTC().Truncate(time.Minute)
+
+	ticker := time.NewTicker(AggregatesStreamRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case c := <-changes:
+			b, ok := open[c.Key]
+			if !ok {
+				continue
+			}
+
+			err = writeEvent(w, "update", api.AggregateResponse{Columns: columns, Rows: []api.AggregateRow{q.row(b, c.Aggregates)}})
+			if err != nil {
+				klog.Error(err)
+				return
+			}
+
+		case now := <-ticker.C:
+			for !next.After(now) {
+				open[q.hash(next)] = next
+
+				row, err := s.getAggregateRow(s.views.Aggregates, q, next)
+				if err != nil {
+					klog.Errorf("can't get aggregate row: %v", err)
+				} else {
+					err = writeEvent(w, "update", api.AggregateResponse{Columns: columns, Rows: []api.AggregateRow{row}})
+					if err != nil {
+						klog.Error(err)
+						return
+					}
+				}
+
+				next = next.Add(time.Minute)
+			}
+
+			if !viewCaughtUp(r.Context(), s.views.Aggregates) {
+				continue
+			}
+
+			ws, err := s.getWatermarks(kafka.SinkGroup)
+			if err != nil {
+				klog.Errorf("can't get watermarks: %v", err)
+				continue
+			}
+			watermark := ws.Min()
+
+			closed := make([]time.Time, 0)
+			for _, b := range open {
+				if bucketCompleteness(b, watermark, true) == api.COMPLETE {
+					closed = append(closed, b)
+				}
+			}
+			sort.Slice(closed, func(i, j int) bool {
+				return closed[i].Before(closed[j])
+			})
+
+			for _, b := range closed {
+				row, err := s.getAggregateRow(s.views.Aggregates, q, b)
+				if err != nil {
+					klog.Errorf("can't get aggregate row: %v", err)
+					continue
+				}
+				row.Completeness = api.COMPLETE
+
+				err = writeEvent(w, "close", api.AggregateResponse{Columns: columns, Rows: []api.AggregateRow{row}})
+				if err != nil {
+					klog.Error(err)
+					return
+				}
+				delete(open, q.hash(b))
+			}
+		}
+	}
+}
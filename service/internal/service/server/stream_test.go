@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/lovoo/goka/storage"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+)
+
+func TestChangeFeedUpdate(t *testing.T) {
+	cf := NewChangeFeed()
+	st := storage.NewMemory()
+
+	changes, unsubscribe := cf.Subscribe()
+
+	ua := api.UserAggregates{Count: 2, SumPrice: 300}
+	value, err := json.Marshal(ua)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cf.Update(nil, st, "key", value)
+	if err != nil {
+		t.Fatalf("can't update: %v", err)
+	}
+
+	stored, err := st.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(value, stored) {
+		t.Errorf("expected the update to be stored: %s != %s", value, stored)
+	}
+
+	expected := AggregateChange{Key: "key", Aggregates: ua}
+	select {
+	case c := <-changes:
+		if !reflect.DeepEqual(expected, c) {
+			t.Errorf("expected and published changes differ: %v != %v", expected, c)
+		}
+	default:
+		t.Errorf("expected a change to be published")
+	}
+
+	unsubscribe()
+	err = cf.Update(nil, st, "key", value)
+	if err != nil {
+		t.Fatalf("can't update: %v", err)
+	}
+
+	select {
+	case c := <-changes:
+		t.Errorf("expected no change after unsubscribing, got %v", c)
+	default:
+	}
+}
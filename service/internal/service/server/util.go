@@ -1,7 +1,12 @@
 package server
 
 import (
+	"context"
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"math"
 	"sort"
+	"time"
 )
 
 func InsertIntoSortedSlice[T any](x T, xs []T, f func(T, []T) func(int) bool) []T {
@@ -12,6 +17,22 @@ func InsertIntoSortedSlice[T any](x T, xs []T, f func(T, []T) func(int) bool) []
 	return xs
 }
 
+// MergeSortedSlices merges slices sorted by less into a single sorted slice.
+func MergeSortedSlices[T any](xs []T, ys []T, less func(T, T) bool) []T {
+	res := make([]T, 0, len(xs)+len(ys))
+	for len(xs) > 0 && len(ys) > 0 {
+		if less(ys[0], xs[0]) {
+			res = append(res, ys[0])
+			ys = ys[1:]
+		} else {
+			res = append(res, xs[0])
+			xs = xs[1:]
+		}
+	}
+	res = append(res, xs...)
+	return append(res, ys...)
+}
+
 func HeadSlice[T any](xs []T, n int) []T {
 	if len(xs) > n {
 		return xs[:n]
@@ -30,3 +51,37 @@ func FilterSlice[T any](xs []T, f func(x T) bool) []T {
 
 	return tmp
 }
+
+// viewCaughtUp reports whether the view has consumed everything its table partitions held at the last high watermark update.
+func viewCaughtUp(ctx context.Context, v *goka.View) bool {
+	if !v.Recovered() {
+		return false
+	}
+
+	for _, ts := range v.Stats(ctx).Partitions {
+		if ts.Input.OffsetLag > caughtUpOffsetLag {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bucketCompleteness tells whether the bucket is complete, i.e. it ends at or before the watermark
+// and the view has caught up with the table the bucket is read from.
+func bucketCompleteness(bucket time.Time, watermark time.Time, caughtUp bool) api.Completeness {
+	if caughtUp && !bucket.Add(time.Minute).After(watermark) {
+		return api.COMPLETE
+	}
+
+	return api.PARTIAL
+}
+
+// nonNegativeAggregateValue rounds a predicted aggregate, which can't be negative.
+func nonNegativeAggregateValue(v float64) api.AggregateValue {
+	if v < 0 {
+		return 0
+	}
+
+	return api.AggregateValue(math.Round(v))
+}
@@ -2,8 +2,10 @@ package server
 
 import (
 	"github.com/google/go-cmp/cmp"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type insertIntoSortedSliceTestCase[T any] struct {
@@ -104,3 +106,93 @@ func TestHeadSlice(t *testing.T) {
 	t.Parallel()
 	t.Run("int test", testInt)
 }
+
+func TestMergeSortedSlices(t *testing.T) {
+	ts := []struct {
+		name     string
+		xs       []int
+		ys       []int
+		expected []int
+	}{
+		{
+			name:     "Merge empty slices",
+			xs:       []int{},
+			ys:       []int{},
+			expected: []int{},
+		},
+		{
+			name:     "Merge interleaved slices",
+			xs:       []int{9, 5, 3},
+			ys:       []int{8, 5, 1},
+			expected: []int{9, 8, 5, 5, 3, 1},
+		},
+		{
+			name:     "Merge with an empty slice",
+			xs:       []int{},
+			ys:       []int{4, 2},
+			expected: []int{4, 2},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res := MergeSortedSlices(test.xs, test.ys, func(a, b int) bool { return a > b })
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %s", cmp.Diff(test.expected, res))
+			}
+		})
+	}
+}
+
+func TestBucketCompleteness(t *testing.T) {
+	bucket := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	ts := []struct {
+		name      string
+		watermark time.Time
+		caughtUp  bool
+		expected  api.Completeness
+	}{
+		{
+			name:      "Bucket ending at the watermark is complete",
+			watermark: bucket.Add(time.Minute),
+			caughtUp:  true,
+			expected:  api.COMPLETE,
+		},
+		{
+			name:      "Bucket ending before the watermark is complete",
+			watermark: bucket.Add(time.Hour),
+			caughtUp:  true,
+			expected:  api.COMPLETE,
+		},
+		{
+			name:      "Bucket ending after the watermark is partial",
+			watermark: bucket.Add(59 * time.Second),
+			caughtUp:  true,
+			expected:  api.PARTIAL,
+		},
+		{
+			name:      "Bucket of a view which hasn't caught up is partial",
+			watermark: bucket.Add(time.Hour),
+			caughtUp:  false,
+			expected:  api.PARTIAL,
+		},
+		{
+			name:      "Bucket is partial without a watermark",
+			watermark: time.Time{},
+			caughtUp:  true,
+			expected:  api.PARTIAL,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res := bucketCompleteness(bucket, test.watermark, test.caughtUp)
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %s", cmp.Diff(test.expected, res))
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ go 1.18
 require (
 	github.com/aerospike/aerospike-client-go/v6 v6.2.1
 	github.com/golang/snappy v0.0.4
+	github.com/google/go-cmp v0.5.7
 	github.com/lovoo/goka v1.1.7
 	k8s.io/klog/v2 v2.70.1
 )
@@ -0,0 +1,41 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"os"
+)
+
+type actionsFile struct {
+	Actions []string `json:"actions"`
+}
+
+// Load reads the actions file and registers the actions it lists, e.g.
+//
+//	{"actions": ["ADD_TO_CART", "WISHLIST", "SEARCH"]}
+//
+// It has to be called before any user tags or definitions referring to the actions are parsed.
+func Load(path string) ([]api.Action, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read actions file: %w", err)
+	}
+
+	var af actionsFile
+	err = json.Unmarshal(data, &af)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal actions file: %w", err)
+	}
+
+	actions := make([]api.Action, 0, len(af.Actions))
+	for _, s := range af.Actions {
+		a, err := api.RegisterAction(s)
+		if err != nil {
+			return nil, fmt.Errorf("can't register action: %w", err)
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, nil
+}
@@ -5,4 +5,5 @@ const (
 	Port           = 3000
 	Namespace      = "mimuw"
 	UserProfileSet = "user_profile"
+	IdentitySet    = "identity"
 )
@@ -2,7 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
 )
 
 type Action int
@@ -10,16 +12,53 @@ type Action int
 const (
 	VIEW Action = iota + 1
 	BUY
+	// REFUND retracts the BUY the user tag's PurchaseTime refers to.
+	REFUND
 )
 
 var actionToString = map[Action]string{
-	VIEW: "VIEW",
-	BUY:  "BUY",
+	VIEW:   "VIEW",
+	BUY:    "BUY",
+	REFUND: "REFUND",
 }
 
 var actionFromString = map[string]Action{
-	"VIEW": VIEW,
-	"BUY":  BUY,
+	"VIEW":   VIEW,
+	"BUY":    BUY,
+	"REFUND": REFUND,
+}
+
+// profileActions lists the actions whose user tags are kept in user profiles.
+var profileActions = []Action{VIEW, BUY}
+
+// ErrUnknownAction is returned for actions which aren't registered.
+var ErrUnknownAction = errors.New("unknown action")
+
+var actionRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// RegisterAction adds an action whose user tags are kept in user profiles and aggregated like VIEWs and BUYs.
+// Actions have to be registered before any user tags are parsed, as the registry isn't safe for concurrent use.
+func RegisterAction(s string) (Action, error) {
+	if !actionRegexp.MatchString(s) {
+		return Action(0), fmt.Errorf("%q is not a valid action name", s)
+	}
+	if _, ok := actionFromString[s]; ok {
+		return Action(0), fmt.Errorf("action %q is already registered", s)
+	}
+
+	a := Action(len(actionToString) + 1)
+	actionToString[a] = s
+	actionFromString[s] = a
+	profileActions = append(profileActions, a)
+
+	return a, nil
+}
+
+// ProfileActions returns the actions whose user tags are kept in user profiles, i.e. all of them except REFUND.
+func ProfileActions() []Action {
+	res := make([]Action, len(profileActions))
+	copy(res, profileActions)
+	return res
 }
 
 func (a Action) MarshalJSON() ([]byte, error) {
@@ -46,12 +85,18 @@ func (a *Action) UnmarshalJSON(data []byte) error {
 func ParseAction(s string) (Action, error) {
 	a, ok := actionFromString[s]
 	if !ok {
-		return Action(0), fmt.Errorf("%q is not a valid action", s)
+		return Action(0), fmt.Errorf("%q: %w", s, ErrUnknownAction)
 	}
 
 	return a, nil
 }
 
+// Known reports whether the action is registered, see UserTagCodec.
+func (a Action) Known() bool {
+	_, ok := actionToString[a]
+	return ok
+}
+
 func (a Action) String() string {
 	return actionToString[a]
 }
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// registerActions registers the actions for the duration of the test. The registry is global, so tests registering
+// actions can't run in parallel with each other.
+func registerActions(t *testing.T, names ...string) []Action {
+	toString := make(map[Action]string, len(actionToString))
+	for a, s := range actionToString {
+		toString[a] = s
+	}
+	fromString := make(map[string]Action, len(actionFromString))
+	for s, a := range actionFromString {
+		fromString[s] = a
+	}
+	profile := append([]Action{}, profileActions...)
+	t.Cleanup(func() {
+		actionToString, actionFromString, profileActions = toString, fromString, profile
+	})
+
+	res := make([]Action, 0, len(names))
+	for _, s := range names {
+		a, err := RegisterAction(s)
+		if err != nil {
+			t.Fatalf("can't register action: %v", err)
+		}
+		res = append(res, a)
+	}
+
+	return res
+}
+
+func TestRegisterAction(t *testing.T) {
+	var err error
+
+	a := registerActions(t, "ADD_TO_CART")[0]
+
+	if a.String() != "ADD_TO_CART" {
+		t.Errorf("expected action %q, got %q", "ADD_TO_CART", a.String())
+	}
+
+	var ut UserTag
+	err = json.Unmarshal([]byte(`{"time": "2022-03-01T10:00:00.000Z", "action": "ADD_TO_CART"}`), &ut)
+	if err != nil {
+		t.Fatalf("can't unmarshal user tag: %v", err)
+	}
+	if ut.Action != a {
+		t.Errorf("expected action %v, got %v", a, ut.Action)
+	}
+
+	expected := []Action{VIEW, BUY, a}
+	pas := ProfileActions()
+	if !reflect.DeepEqual(expected, pas) {
+		t.Errorf("expected and computed results differ: %v, %v", expected, pas)
+	}
+
+	for _, s := range []string{"VIEW", "ADD_TO_CART", "add_to_cart", ""} {
+		_, err = RegisterAction(s)
+		if err == nil {
+			t.Errorf("expected an error registering %q", s)
+		}
+	}
+}
+
+func TestUserProfileTags(t *testing.T) {
+	wishlist := registerActions(t, "WISHLIST")[0]
+
+	up := UserProfile{}
+	up.SetTags(VIEW, []UserTag{{Action: VIEW, Device: PC}})
+	up.SetTags(wishlist, []UserTag{{Action: wishlist, Device: PC}})
+
+	data, err := json.Marshal(up)
+	if err != nil {
+		t.Fatalf("can't marshal user profile: %v", err)
+	}
+
+	// VIEWs and BUYs keep their own keys, the user tags of other actions are keyed by the action within "actions".
+	var decoded struct {
+		Views   []UserTag            `json:"views"`
+		Buys    []UserTag            `json:"buys"`
+		Actions map[string][]UserTag `json:"actions"`
+	}
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatalf("can't unmarshal user profile: %v", err)
+	}
+
+	if len(decoded.Views) != 1 || len(decoded.Buys) != 0 || len(decoded.Actions) != 1 || len(decoded.Actions["WISHLIST"]) != 1 {
+		t.Errorf("expected a VIEW and a WISHLIST user tag, got %s", data)
+	}
+	if decoded.Actions["WISHLIST"][0].Action != wishlist {
+		t.Errorf("expected and computed results differ: %v, %v", wishlist, decoded.Actions["WISHLIST"][0].Action)
+	}
+
+	if len(up.Tags(BUY)) != 0 || len(up.Tags(wishlist)) != 1 {
+		t.Errorf("expected user tags of the action to be kept separately")
+	}
+}
@@ -3,7 +3,6 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"reflect"
 	"strconv"
 	"time"
 )
@@ -13,79 +12,57 @@ type Aggregate int
 const (
 	AGGREGATE_SUM_PRICE Aggregate = iota + 1
 	AGGREGATE_COUNT
+	AGGREGATE_LATE_SUM_PRICE
+	AGGREGATE_LATE_COUNT
 )
 
 var stringToAggregate = map[string]Aggregate{
-	"SUM_PRICE": AGGREGATE_SUM_PRICE,
-	"COUNT":     AGGREGATE_COUNT,
+	"SUM_PRICE":      AGGREGATE_SUM_PRICE,
+	"COUNT":          AGGREGATE_COUNT,
+	"LATE_SUM_PRICE": AGGREGATE_LATE_SUM_PRICE,
+	"LATE_COUNT":     AGGREGATE_LATE_COUNT,
 }
 
 func ParseAggregate(s string) (Aggregate, error) {
 	a, ok := stringToAggregate[s]
 	if !ok {
-		return Aggregate(0), fmt.Errorf("%q is not a valid aggregate")
+		return Aggregate(0), fmt.Errorf("%q is not a valid aggregate", s)
 	}
 
 	return a, nil
 }
 
 var aggregateToAggregateColumns = map[Aggregate]AggregateColumn{
-	AGGREGATE_SUM_PRICE: SUM_PRICE,
-	AGGREGATE_COUNT:     COUNT,
+	AGGREGATE_SUM_PRICE:      SUM_PRICE,
+	AGGREGATE_COUNT:          COUNT,
+	AGGREGATE_LATE_SUM_PRICE: LATE_SUM_PRICE,
+	AGGREGATE_LATE_COUNT:     LATE_COUNT,
 }
 
 func AggregateToAggregateColumn(a Aggregate) AggregateColumn {
 	return aggregateToAggregateColumns[a]
 }
 
-type AggregateColumn int
+type AggregateColumn string
 
 const (
-	BUCKET AggregateColumn = iota + 1
-	ACTION
-	ORIGIN
-	BRAND_ID
-	CATEGORY_ID
-	SUM_PRICE
-	COUNT
+	BUCKET         AggregateColumn = "1m_bucket"
+	ACTION         AggregateColumn = "action"
+	SUM_PRICE      AggregateColumn = "sum_price"
+	COUNT          AggregateColumn = "count"
+	LATE_SUM_PRICE AggregateColumn = "late_sum_price"
+	LATE_COUNT     AggregateColumn = "late_count"
+	COMPLETENESS   AggregateColumn = "completeness"
 )
 
-//var columnToIndex = map[string]int{
-//	"1m_bucket":   0,
-//	"action":      1,
-//	"origin":      2,
-//	"brand_id":    3,
-//	"category_id": 4,
-//	"sum_price":   5,
-//	"count":       6,
-//}
-
-var aggregateColumnToString = map[AggregateColumn]string{
-	BUCKET:      "1m_bucket",
-	ACTION:      "action",
-	ORIGIN:      "origin",
-	BRAND_ID:    "brand_id",
-	CATEGORY_ID: "category_id",
-	SUM_PRICE:   "sum_price",
-	COUNT:       "count",
+// LowerColumn returns the column of the lower confidence bound of a forecast aggregate column.
+func LowerColumn(c AggregateColumn) AggregateColumn {
+	return c + "_lower"
 }
 
-var aggregateColumnToIndex = map[AggregateColumn]int{
-	BUCKET:      0,
-	ACTION:      1,
-	ORIGIN:      2,
-	BRAND_ID:    3,
-	CATEGORY_ID: 4,
-	SUM_PRICE:   5,
-	COUNT:       6,
-}
-
-func (ac AggregateColumn) string() string {
-	return aggregateColumnToString[ac]
-}
-
-func (ac AggregateColumn) MarshalJSON() ([]byte, error) {
-	return json.Marshal(ac.string())
+// UpperColumn returns the column of the upper confidence bound of a forecast aggregate column.
+func UpperColumn(c AggregateColumn) AggregateColumn {
+	return c + "_upper"
 }
 
 type AggregateResponse struct {
@@ -105,14 +82,60 @@ func (av AggregateValue) MarshalJSON() ([]byte, error) {
 	return json.Marshal(strconv.FormatInt(int64(av), 10))
 }
 
+type Completeness int
+
+const (
+	// PARTIAL marks buckets which may still receive user tags.
+	PARTIAL Completeness = iota + 1
+	// COMPLETE marks buckets which are behind the collector's watermark and fully read from its table.
+	COMPLETE
+)
+
+var completenessToString = map[Completeness]string{
+	PARTIAL:  "PARTIAL",
+	COMPLETE: "COMPLETE",
+}
+
+func (c Completeness) MarshalJSON() ([]byte, error) {
+	return json.Marshal(completenessToString[c])
+}
+
 type AggregateRow struct {
-	Bucket     BucketTime
-	Action     Action
-	Origin     string // FIXME
-	BrandID    string
-	CategoryID string
-	SumPrice   AggregateValue
-	Count      AggregateValue
+	Bucket BucketTime
+	Action Action
+	// Dimensions holds the values of the filtered dimensions keyed by their columns.
+	Dimensions   map[AggregateColumn]string
+	SumPrice     AggregateValue
+	Count        AggregateValue
+	LateSumPrice AggregateValue
+	LateCount    AggregateValue
+	Completeness Completeness
+	// Bounds holds the confidence bounds of forecast aggregates keyed by their LowerColumn and UpperColumn.
+	Bounds map[AggregateColumn]AggregateValue
+}
+
+func (ar AggregateRow) value(c AggregateColumn) interface{} {
+	switch c {
+	case BUCKET:
+		return ar.Bucket
+	case ACTION:
+		return ar.Action
+	case SUM_PRICE:
+		return ar.SumPrice
+	case COUNT:
+		return ar.Count
+	case LATE_SUM_PRICE:
+		return ar.LateSumPrice
+	case LATE_COUNT:
+		return ar.LateCount
+	case COMPLETENESS:
+		return ar.Completeness
+	default:
+		if v, ok := ar.Bounds[c]; ok {
+			return v
+		}
+		return ar.Dimensions[c]
+	}
 }
 
 func (ar AggregateResponse) MarshalJSON() ([]byte, error) {
@@ -126,10 +149,9 @@ func (ar AggregateResponse) MarshalJSON() ([]byte, error) {
 	}
 
 	for ir, r := range ar.Rows {
-		data := reflect.ValueOf(r)
 		dataArray := make([]interface{}, len(ar.Columns))
 		for ic, c := range ar.Columns {
-			dataArray[ic] = data.Field(aggregateColumnToIndex[c]).Interface()
+			dataArray[ic] = r.value(c)
 		}
 		aux.Rows[ir] = dataArray
 	}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAggregateResponseMarshalJSON(t *testing.T) {
+	bucket := time.Date(2022, 3, 1, 0, 5, 0, 0, time.UTC)
+
+	ts := []struct {
+		name     string
+		response AggregateResponse
+		expected string
+	}{
+		{
+			name: "Columns follow the given order and dimensions come from the row",
+			response: AggregateResponse{
+				Columns: []AggregateColumn{BUCKET, ACTION, "brand_id", SUM_PRICE, COUNT},
+				Rows: []AggregateRow{
+					{
+						Bucket:     BucketTime(bucket),
+						Action:     BUY,
+						Dimensions: map[AggregateColumn]string{"brand_id": "Nike"},
+						SumPrice:   1000,
+						Count:      3,
+					},
+				},
+			},
+			expected: `{"columns":["1m_bucket","action","brand_id","sum_price","count"],"rows":[["2022-03-01T00:05:00","BUY","Nike","1000","3"]]}`,
+		},
+		{
+			name: "Forecast bounds follow their aggregate columns",
+			response: AggregateResponse{
+				Columns: []AggregateColumn{BUCKET, ACTION, COUNT, LowerColumn(COUNT), UpperColumn(COUNT)},
+				Rows: []AggregateRow{
+					{
+						Bucket: BucketTime(bucket),
+						Action: VIEW,
+						Count:  10,
+						Bounds: map[AggregateColumn]AggregateValue{LowerColumn(COUNT): 8, UpperColumn(COUNT): 12},
+					},
+				},
+			},
+			expected: `{"columns":["1m_bucket","action","count","count_lower","count_upper"],"rows":[["2022-03-01T00:05:00","VIEW","10","8","12"]]}`,
+		},
+		{
+			name: "Empty response has no rows",
+			response: AggregateResponse{
+				Columns: []AggregateColumn{BUCKET, ACTION, COUNT},
+				Rows:    []AggregateRow{},
+			},
+			expected: `{"columns":["1m_bucket","action","count"],"rows":[]}`,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := json.Marshal(test.response)
+			if err != nil {
+				t.Fatalf("can't marshal response: %v", err)
+			}
+
+			if test.expected != string(res) {
+				t.Errorf("expected and computed results differ: %s != %s", test.expected, res)
+			}
+		})
+	}
+}
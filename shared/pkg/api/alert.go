@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type AlertStatus string
+
+const (
+	FIRING   AlertStatus = "firing"
+	RESOLVED AlertStatus = "resolved"
+)
+
+// Alert is a notification about a rule starting or ceasing to fire.
+type Alert struct {
+	Rule   string      `json:"rule"`
+	Status AlertStatus `json:"status"`
+	// Bucket is the bucket whose evaluation changed the status of the rule.
+	Bucket    time.Time `json:"bucket"`
+	Value     int64     `json:"value"`
+	Threshold int64     `json:"threshold"`
+	// Since is the first bucket of the breach.
+	Since time.Time `json:"since"`
+}
+
+type AlertCodec struct{}
+
+func (c *AlertCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *AlertCodec) Decode(data []byte) (interface{}, error) {
+	var a Alert
+	err := json.Unmarshal(data, &a)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return a, nil
+}
+
+// AlertRuleState holds the progress of a rule's breach.
+type AlertRuleState struct {
+	// Breaches is the number of consecutive closed buckets breaching the rule.
+	Breaches int       `json:"breaches"`
+	Since    time.Time `json:"since,omitempty"`
+	Firing   bool      `json:"firing,omitempty"`
+}
+
+// AlertState holds the progress of the rule engine over the closed buckets.
+type AlertState struct {
+	Clock BucketClock               `json:"clock"`
+	Rules map[string]AlertRuleState `json:"rules"`
+}
+
+type AlertStateCodec struct{}
+
+func (c *AlertStateCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *AlertStateCodec) Decode(data []byte) (interface{}, error) {
+	var as AlertState
+	err := json.Unmarshal(data, &as)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return as, nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Baseline is the exponentially weighted moving average and variance of a series.
+type Baseline struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Samples  int     `json:"samples"`
+}
+
+// AnomalyState holds the progress of the detector over the closed buckets.
+type AnomalyState struct {
+	Clock BucketClock `json:"clock"`
+	// Baselines holds the baselines of each series, one per seasonal slot.
+	Baselines map[string][]Baseline `json:"baselines"`
+}
+
+type AnomalyStateCodec struct{}
+
+func (c *AnomalyStateCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *AnomalyStateCodec) Decode(data []byte) (interface{}, error) {
+	var as AnomalyState
+	err := json.Unmarshal(data, &as)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return as, nil
+}
+
+// Anomaly is a bucket of a series which deviates from the baseline.
+type Anomaly struct {
+	Series   string    `json:"series"`
+	Bucket   time.Time `json:"bucket"`
+	Expected float64   `json:"expected"`
+	Actual   int64     `json:"actual"`
+	ZScore   float64   `json:"z_score"`
+}
+
+type AnomalyCodec struct{}
+
+func (c *AnomalyCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *AnomalyCodec) Decode(data []byte) (interface{}, error) {
+	var a Anomaly
+	err := json.Unmarshal(data, &a)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return a, nil
+}
+
+// Anomalies holds the anomalies flagged in a bucket.
+type Anomalies struct {
+	Anomalies []Anomaly `json:"anomalies"`
+}
+
+type AnomaliesCodec struct{}
+
+func (c *AnomaliesCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *AnomaliesCodec) Decode(data []byte) (interface{}, error) {
+	var as Anomalies
+	err := json.Unmarshal(data, &as)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return as, nil
+}
+
+type AnomaliesResponse struct {
+	Anomalies []Anomaly `json:"anomalies"`
+}
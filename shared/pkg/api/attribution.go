@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Touch is the most recent VIEW of a product by a cookie.
+type Touch struct {
+	ProductID uint64    `json:"product_id"`
+	Origin    string    `json:"origin"`
+	Time      time.Time `json:"time"`
+}
+
+// AttributedPurchase is a BUY credited to an origin other than its own, identified by its PurchaseKey.
+// Time is zero for the purchases remembered before it was recorded.
+type AttributedPurchase struct {
+	Key    string    `json:"key"`
+	Origin string    `json:"origin"`
+	Time   time.Time `json:"time,omitempty"`
+}
+
+// AttributionState holds the touches of a cookie, most recent first. It also holds the most recently attributed BUYs,
+// so that their REFUNDs are retracted from the origins the BUYs were credited to.
+type AttributionState struct {
+	Touches   []Touch              `json:"touches"`
+	Purchases []AttributedPurchase `json:"purchases,omitempty"`
+}
+
+type AttributionStateCodec struct{}
+
+func (c *AttributionStateCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *AttributionStateCodec) Decode(data []byte) (interface{}, error) {
+	var as AttributionState
+	err := json.Unmarshal(data, &as)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return as, nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MarginBand groups products by their margins.
+type MarginBand string
+
+const (
+	MARGIN_LOW    MarginBand = "LOW"
+	MARGIN_MEDIUM MarginBand = "MEDIUM"
+	MARGIN_HIGH   MarginBand = "HIGH"
+)
+
+// Margins below the thresholds belong to the LOW and MEDIUM bands respectively.
+const (
+	MarginLowThreshold    = 0.2
+	MarginMediumThreshold = 0.4
+)
+
+// CatalogProduct holds the attributes of a product in the product catalog.
+type CatalogProduct struct {
+	// CategoryPath lists the categories of the product from the most general one, e.g. CLOTHING, SHOES, WOMEN_SHOES.
+	CategoryPath []string `json:"category_path,omitempty"`
+	Gender       string   `json:"gender,omitempty"`
+	// Margin is the fraction of the price which is profit.
+	Margin float64 `json:"margin"`
+}
+
+func (cp *CatalogProduct) MarginBand() MarginBand {
+	switch {
+	case cp.Margin < MarginLowThreshold:
+		return MARGIN_LOW
+	case cp.Margin < MarginMediumThreshold:
+		return MARGIN_MEDIUM
+	default:
+		return MARGIN_HIGH
+	}
+}
+
+func (cp *CatalogProduct) department() string {
+	if cp == nil || len(cp.CategoryPath) == 0 {
+		return ""
+	}
+	return cp.CategoryPath[0]
+}
+
+func (cp *CatalogProduct) gender() string {
+	if cp == nil {
+		return ""
+	}
+	return cp.Gender
+}
+
+func (cp *CatalogProduct) marginBand() string {
+	if cp == nil {
+		return ""
+	}
+	return string(cp.MarginBand())
+}
+
+type CatalogProductCodec struct{}
+
+func (c *CatalogProductCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *CatalogProductCodec) Decode(data []byte) (interface{}, error) {
+	var cp CatalogProduct
+	err := json.Unmarshal(data, &cp)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return cp, nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CohortKind is the property cookies are grouped into cohorts by.
+type CohortKind string
+
+const (
+	// FIRST_SEEN groups cookies by the day of their first user tag.
+	FIRST_SEEN CohortKind = "first_seen"
+	// FIRST_PURCHASE_BRAND groups cookies by the brand of their first BUY.
+	FIRST_PURCHASE_BRAND CohortKind = "first_purchase_brand"
+)
+
+var CohortKinds = []CohortKind{FIRST_SEEN, FIRST_PURCHASE_BRAND}
+
+func ParseCohortKind(s string) (CohortKind, error) {
+	for _, k := range CohortKinds {
+		if string(k) == s {
+			return k, nil
+		}
+	}
+
+	return CohortKind(""), fmt.Errorf("%q is not a valid cohort kind", s)
+}
+
+// CohortPeriod is the length of a retention period.
+const CohortPeriod = 24 * time.Hour
+
+// CohortMaxPeriods is the number of periods which fit into the bit set of periods reported per cookie.
+const CohortMaxPeriods = 64
+
+// CohortKey returns the key of a cohort in the cohort table.
+func CohortKey(kind CohortKind, cohort string) string {
+	return fmt.Sprintf("%s=%s", kind, cohort)
+}
+
+// CohortMembership is the cohort of a cookie of a given kind.
+type CohortMembership struct {
+	Cohort string    `json:"cohort"`
+	Start  time.Time `json:"start"`
+	// Reported holds a bit for each period the cookie's activity of an action has been reported in, keyed by the action.
+	Reported map[string]uint64 `json:"reported,omitempty"`
+}
+
+// CohortState holds the cohorts a cookie belongs to keyed by their kinds.
+type CohortState struct {
+	Memberships map[CohortKind]CohortMembership `json:"memberships"`
+}
+
+type CohortStateCodec struct{}
+
+func (c *CohortStateCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *CohortStateCodec) Decode(data []byte) (interface{}, error) {
+	var cs CohortState
+	err := json.Unmarshal(data, &cs)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return cs, nil
+}
+
+// CohortEvent reports a cookie joining a cohort or being active in one of its periods.
+type CohortEvent struct {
+	Joined bool   `json:"joined,omitempty"`
+	Action Action `json:"action,omitempty"`
+	Period int    `json:"period"`
+}
+
+type CohortEventCodec struct{}
+
+func (c *CohortEventCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *CohortEventCodec) Decode(data []byte) (interface{}, error) {
+	var ce CohortEvent
+	err := json.Unmarshal(data, &ce)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return ce, nil
+}
+
+// CohortAggregates counts the members of a cohort and the members active in each period, per action.
+type CohortAggregates struct {
+	Size   int64              `json:"size"`
+	Active map[string][]int64 `json:"active"`
+}
+
+type CohortAggregatesCodec struct{}
+
+func (c *CohortAggregatesCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *CohortAggregatesCodec) Decode(data []byte) (interface{}, error) {
+	var ca CohortAggregates
+	err := json.Unmarshal(data, &ca)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return ca, nil
+}
+
+type CohortRow struct {
+	Cohort string `json:"cohort"`
+	Size   int64  `json:"size"`
+	// Active counts the members active in each period and Retention holds their fraction of the cohort.
+	Active    []int64   `json:"active"`
+	Retention []float64 `json:"retention"`
+}
+
+type CohortsResponse struct {
+	By      CohortKind  `json:"by"`
+	Action  Action      `json:"action"`
+	Cohorts []CohortRow `json:"cohorts"`
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/topk"
+	"time"
+)
+
+type RecentProduct struct {
+	ProductID string    `json:"product_id"`
+	Action    Action    `json:"action"`
+	Time      time.Time `json:"time"`
+}
+
+// RecentProducts holds the distinct products a cookie interacted with recently per action, most recent first.
+type RecentProducts struct {
+	Products []RecentProduct `json:"products"`
+}
+
+type RecentProductsCodec struct{}
+
+func (c *RecentProductsCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *RecentProductsCodec) Decode(data []byte) (interface{}, error) {
+	var rp RecentProducts
+	err := json.Unmarshal(data, &rp)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return rp, nil
+}
+
+// CooccurrenceKey identifies the products a cookie took action on after viewing productID.
+func CooccurrenceKey(action Action, productID string) string {
+	return fmt.Sprintf("%s/%s", action, productID)
+}
+
+// CooccurrenceEvent is emitted for a product when a cookie views it and interacts with ProductID within the window.
+// It's keyed by CooccurrenceKey of the action taken on ProductID.
+type CooccurrenceEvent struct {
+	ProductID string    `json:"product_id"`
+	Time      time.Time `json:"time"`
+}
+
+type CooccurrenceEventCodec struct{}
+
+func (c *CooccurrenceEventCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *CooccurrenceEventCodec) Decode(data []byte) (interface{}, error) {
+	var ce CooccurrenceEvent
+	err := json.Unmarshal(data, &ce)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return ce, nil
+}
+
+// Cooccurrences counts the products co-occurring with a product in hourly sketches.
+type Cooccurrences struct {
+	topk.Hourly
+}
+
+type CooccurrencesCodec struct{}
+
+func (c *CooccurrencesCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *CooccurrencesCodec) Decode(data []byte) (interface{}, error) {
+	var co Cooccurrences
+	err := json.Unmarshal(data, &co)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return co, nil
+}
+
+type Recommendation struct {
+	ProductID string         `json:"product_id"`
+	Score     AggregateValue `json:"score"`
+}
+
+type RecommendationsResponse struct {
+	ProductID  string           `json:"product_id,omitempty"`
+	Cookie     string           `json:"cookie,omitempty"`
+	AlsoViewed []Recommendation `json:"also_viewed"`
+	AlsoBought []Recommendation `json:"also_bought"`
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dimension is a property of user tags which aggregates can be filtered by.
+type Dimension struct {
+	// Column names both the query parameter and the response column of the dimension.
+	Column AggregateColumn
+	// Extract returns the value of the dimension for the given user tag.
+	Extract func(ut *UserTag) string
+	// Validate checks a filter value supplied in a query. It's optional.
+	Validate func(s string) error
+	// Group names the dimensions which can't be combined, because they're derived from each other. It's optional.
+	Group string
+	// Derived dimensions are only known for user tags enriched by the forwarder.
+	Derived bool
+}
+
+const (
+	// GeoGroup groups the country and the dimensions derived from it.
+	GeoGroup = "geo"
+	// CatalogGroup groups the dimensions of the product catalog. They're exclusive to bound the number of aggregates of each user tag.
+	CatalogGroup = "catalog"
+)
+
+var (
+	OriginDimension = Dimension{
+		Column:  "origin",
+		Extract: func(ut *UserTag) string { return ut.Origin },
+	}
+	BrandDimension = Dimension{
+		Column:  "brand_id",
+		Extract: func(ut *UserTag) string { return ut.Product.BrandID },
+	}
+	CategoryDimension = Dimension{
+		Column:  "category_id",
+		Extract: func(ut *UserTag) string { return ut.Product.CategoryID },
+	}
+	CountryDimension = Dimension{
+		Column:  "country",
+		Extract: func(ut *UserTag) string { return ut.Country },
+		Group:   GeoGroup,
+	}
+	DeviceDimension = Dimension{
+		Column:  "device",
+		Extract: func(ut *UserTag) string { return ut.Device.String() },
+		Validate: func(s string) error {
+			_, err := ParseDevice(s)
+			return err
+		},
+	}
+	RegionDimension = Dimension{
+		Column:  "region",
+		Extract: func(ut *UserTag) string { return ut.Geo.region() },
+		Group:   GeoGroup,
+		Derived: true,
+	}
+	MarketDimension = Dimension{
+		Column:  "market",
+		Extract: func(ut *UserTag) string { return ut.Geo.market() },
+		Group:   GeoGroup,
+		Derived: true,
+	}
+	LocalCurrencyDimension = Dimension{
+		Column:  "local_currency",
+		Extract: func(ut *UserTag) string { return ut.Geo.currency() },
+		Group:   GeoGroup,
+		Derived: true,
+	}
+	DepartmentDimension = Dimension{
+		Column:  "department",
+		Extract: func(ut *UserTag) string { return ut.Catalog.department() },
+		Group:   CatalogGroup,
+		Derived: true,
+	}
+	GenderDimension = Dimension{
+		Column:  "gender",
+		Extract: func(ut *UserTag) string { return ut.Catalog.gender() },
+		Group:   CatalogGroup,
+		Derived: true,
+	}
+	MarginBandDimension = Dimension{
+		Column:  "margin_band",
+		Extract: func(ut *UserTag) string { return ut.Catalog.marginBand() },
+		Group:   CatalogGroup,
+		Derived: true,
+		Validate: func(s string) error {
+			switch MarginBand(s) {
+			case MARGIN_LOW, MARGIN_MEDIUM, MARGIN_HIGH:
+				return nil
+			default:
+				return fmt.Errorf("%q is not a valid margin band", s)
+			}
+		},
+	}
+	// ProductDimension is too fine-grained to filter aggregates by, but it's used for ranking.
+	ProductDimension = Dimension{
+		Column:  "product_id",
+		Extract: func(ut *UserTag) string { return strconv.FormatUint(ut.Product.ProductID, 10) },
+	}
+)
+
+// Dimensions is the registry of aggregate dimensions.
+// The ones aggregated by a deployment, see EnabledDimensions, drive the keys emitted by the forwarder, the filters
+// accepted by the service and the order of response columns.
+var Dimensions = []Dimension{
+	OriginDimension,
+	BrandDimension,
+	CategoryDimension,
+	CountryDimension,
+	DeviceDimension,
+	RegionDimension,
+	MarketDimension,
+	LocalCurrencyDimension,
+	DepartmentDimension,
+	GenderDimension,
+	MarginBandDimension,
+}
+
+// OptionalGroups lists the groups whose dimensions are only aggregated by the deployments which enable them,
+// since each of them multiplies the number of aggregates of every user tag.
+var OptionalGroups = []string{GeoGroup, CatalogGroup}
+
+// EnabledDimensions returns the dimensions aggregated with the given comma-separated optional groups enabled.
+func EnabledDimensions(groups string) ([]Dimension, error) {
+	disabled := make(map[string]bool, len(OptionalGroups))
+	for _, g := range OptionalGroups {
+		disabled[g] = true
+	}
+	for _, g := range strings.Split(groups, ",") {
+		if len(g) == 0 {
+			continue
+		}
+		if _, ok := disabled[g]; !ok {
+			return nil, fmt.Errorf("%q is not an optional dimension group", g)
+		}
+		disabled[g] = false
+	}
+
+	ds := make([]Dimension, 0, len(Dimensions))
+	for _, d := range Dimensions {
+		if disabled[d.Group] {
+			continue
+		}
+		ds = append(ds, d)
+	}
+
+	return ds, nil
+}
+
+// Combinable reports whether the dimensions can be used together, i.e. whether no two of them belong to the same group.
+func Combinable(ds []Dimension) bool {
+	groups := make(map[string]bool)
+	for _, d := range ds {
+		if len(d.Group) == 0 {
+			continue
+		}
+		if groups[d.Group] {
+			return false
+		}
+		groups[d.Group] = true
+	}
+
+	return true
+}
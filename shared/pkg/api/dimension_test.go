@@ -0,0 +1,55 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnabledDimensions(t *testing.T) {
+	ts := []struct {
+		name          string
+		groups        string
+		expected      []AggregateColumn
+		expectedError bool
+	}{
+		{
+			name:     "Optional groups are disabled by default",
+			groups:   "",
+			expected: []AggregateColumn{"origin", "brand_id", "category_id", "device"},
+		},
+		{
+			name:     "Enabled groups add all their dimensions",
+			groups:   "catalog",
+			expected: []AggregateColumn{"origin", "brand_id", "category_id", "device", "department", "gender", "margin_band"},
+		},
+		{
+			name:          "Unknown groups are rejected",
+			groups:        "geo,weather",
+			expectedError: true,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			ds, err := EnabledDimensions(test.groups)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			columns := make([]AggregateColumn, 0, len(ds))
+			for _, d := range ds {
+				columns = append(columns, d.Column)
+			}
+			if !reflect.DeepEqual(test.expected, columns) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, columns)
+			}
+		})
+	}
+}
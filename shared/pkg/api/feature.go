@@ -0,0 +1,25 @@
+package api
+
+import "time"
+
+// FeatureBucket holds the contribution of the user tags of a slice of a feature's window.
+type FeatureBucket struct {
+	Start time.Time `json:"start"`
+	Sum   int64     `json:"sum,omitempty"`
+	// Values holds the distinct values of the bucket's user tags for distinct counters.
+	Values []string `json:"values,omitempty"`
+}
+
+// FeatureState is a sliding-window counter of a feature.
+type FeatureState struct {
+	// Time is the time of the most recent user tag the counter has seen.
+	Time    time.Time       `json:"time"`
+	Buckets []FeatureBucket `json:"buckets,omitempty"`
+}
+
+type FeaturesResponse struct {
+	Cookie string `json:"cookie"`
+	// AsOf is the end of the windows, i.e. the time of the most recent user tag the counters have seen.
+	AsOf     time.Time        `json:"as_of"`
+	Features map[string]int64 `json:"features"`
+}
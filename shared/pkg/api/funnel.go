@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FunnelDimensions lists the dimensions a VIEW-to-BUY funnel can be narrowed down with.
+// A cookie converts when it buys a product matching the same filters it viewed.
+var FunnelDimensions = []Dimension{
+	ProductDimension,
+	BrandDimension,
+	CategoryDimension,
+}
+
+// FunnelMark records that a cookie viewed products matching Filters in the given bucket.
+// ConvertedBy is the PurchaseKey of the BUY which converted it, so that its REFUND can retract the conversion.
+type FunnelMark struct {
+	Bucket      time.Time `json:"bucket"`
+	Filters     []string  `json:"filters"`
+	ViewedAt    time.Time `json:"viewed_at"`
+	Converted   bool      `json:"converted,omitempty"`
+	ConvertedBy string    `json:"converted_by,omitempty"`
+}
+
+// FunnelState is the per-cookie state of the funnel processor.
+// Marks are keyed by the aggregate hash of their bucket and filters.
+type FunnelState struct {
+	Marks map[string]FunnelMark `json:"marks_by_key"`
+}
+
+type FunnelStateCodec struct{}
+
+func (c *FunnelStateCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *FunnelStateCodec) Decode(data []byte) (interface{}, error) {
+	var fs FunnelState
+	err := json.Unmarshal(data, &fs)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return fs, nil
+}
+
+// FunnelEvent is emitted once per cookie when it first views matching products in a bucket
+// and once more when it converts, with Delay measured from the first view.
+// Retracted conversion events are emitted when the BUY which converted the cookie is refunded.
+// View events carry MaxWindow, the longest delay conversions are tracked for.
+type FunnelEvent struct {
+	Converted bool          `json:"converted,omitempty"`
+	Delay     time.Duration `json:"delay,omitempty"`
+	Retracted bool          `json:"retracted,omitempty"`
+	MaxWindow time.Duration `json:"max_window,omitempty"`
+}
+
+type FunnelEventCodec struct{}
+
+func (c *FunnelEventCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *FunnelEventCodec) Decode(data []byte) (interface{}, error) {
+	var fe FunnelEvent
+	err := json.Unmarshal(data, &fe)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return fe, nil
+}
+
+// FunnelAggregates counts distinct viewing cookies of a bucket
+// and histograms the ones which converted by the number of full hours it took them.
+// MaxWindow is the shortest of the windows conversions of the viewers were tracked for, zero if it's unknown.
+type FunnelAggregates struct {
+	Viewers   int64         `json:"viewers"`
+	Converted []int64       `json:"converted"`
+	MaxWindow time.Duration `json:"max_window,omitempty"`
+}
+
+// ConvertedWithin returns the number of viewers which converted in less than window.
+func (fa FunnelAggregates) ConvertedWithin(window time.Duration) int64 {
+	var res int64
+	for i, c := range fa.Converted {
+		if time.Duration(i)*time.Hour >= window {
+			break
+		}
+		res += c
+	}
+
+	return res
+}
+
+type FunnelAggregatesCodec struct{}
+
+func (c *FunnelAggregatesCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *FunnelAggregatesCodec) Decode(data []byte) (interface{}, error) {
+	var fa FunnelAggregates
+	err := json.Unmarshal(data, &fa)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return fa, nil
+}
+
+type FunnelRow struct {
+	Bucket         BucketTime     `json:"1m_bucket"`
+	Viewers        AggregateValue `json:"viewers"`
+	Buyers         AggregateValue `json:"buyers"`
+	ConversionRate string         `json:"conversion_rate"`
+}
+
+type FunnelResponse struct {
+	Window  string                     `json:"window"`
+	Filters map[AggregateColumn]string `json:"filters"`
+	Rows    []FunnelRow                `json:"rows"`
+}
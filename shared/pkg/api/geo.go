@@ -0,0 +1,29 @@
+package api
+
+// Geo holds the dimensions derived from the country of a user tag.
+type Geo struct {
+	Region   string `json:"region"`
+	Market   string `json:"market"`
+	Currency string `json:"currency"`
+}
+
+func (g *Geo) region() string {
+	if g == nil {
+		return ""
+	}
+	return g.Region
+}
+
+func (g *Geo) market() string {
+	if g == nil {
+		return ""
+	}
+	return g.Market
+}
+
+func (g *Geo) currency() string {
+	if g == nil {
+		return ""
+	}
+	return g.Currency
+}
@@ -0,0 +1,22 @@
+package api
+
+// IdentityNode is a node of the identity graph, i.e. a cookie or a login ID.
+type IdentityNode struct {
+	// Parent is the key of the node's parent. It's empty for the roots of linked components.
+	Parent string `json:"parent,omitempty"`
+	// Cookies holds the sorted cookies of the component of a root.
+	Cookies []string `json:"cookies,omitempty"`
+}
+
+// IdentityLinkRequest links the cookies with each other and with the login ID, if it's given.
+type IdentityLinkRequest struct {
+	LoginID string   `json:"login_id,omitempty"`
+	Cookies []string `json:"cookies"`
+}
+
+type IdentityResponse struct {
+	Cookie string `json:"cookie"`
+	// UserID is the key of the root of the cookie's component, so it's the smallest login ID linked with the cookie if there's any.
+	UserID  string   `json:"user_id"`
+	Cookies []string `json:"cookies"`
+}
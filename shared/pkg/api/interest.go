@@ -0,0 +1,16 @@
+package api
+
+import "time"
+
+type InterestRow struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+type UserInterestsResponse struct {
+	Cookie string `json:"cookie"`
+	// AsOf is the time the scores are decayed to, i.e. the time of the cookie's most recent user tag.
+	AsOf       time.Time     `json:"as_of"`
+	Brands     []InterestRow `json:"brands"`
+	Categories []InterestRow `json:"categories"`
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/topk"
+	"strings"
+	"time"
+)
+
+// PathSeparator joins the steps of a path into a sketch item.
+const PathSeparator = ">"
+
+func JoinPath(steps []string) string {
+	return strings.Join(steps, PathSeparator)
+}
+
+func SplitPath(item string) []string {
+	return strings.Split(item, PathSeparator)
+}
+
+// PathKey returns the key of the paths of the given length leading to purchases of the target in the path table.
+// Targets are either categories or brands.
+func PathKey(target AggregateColumn, value string, length int) string {
+	return fmt.Sprintf("%s=%s;%d", target, value, length)
+}
+
+type PathStep struct {
+	CategoryID string    `json:"category_id"`
+	Time       time.Time `json:"time"`
+}
+
+// RecentCategories holds the categories a cookie browsed recently, oldest first. Repeated views of a category make a single step.
+type RecentCategories struct {
+	Steps []PathStep `json:"steps"`
+}
+
+type RecentCategoriesCodec struct{}
+
+func (c *RecentCategoriesCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *RecentCategoriesCodec) Decode(data []byte) (interface{}, error) {
+	var rc RecentCategories
+	err := json.Unmarshal(data, &rc)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return rc, nil
+}
+
+// PathEvent reports the categories browsed before a BUY, ending with the category of the bought product.
+type PathEvent struct {
+	Steps []string  `json:"steps"`
+	Time  time.Time `json:"time"`
+}
+
+type PathEventCodec struct{}
+
+func (c *PathEventCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *PathEventCodec) Decode(data []byte) (interface{}, error) {
+	var pe PathEvent
+	err := json.Unmarshal(data, &pe)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return pe, nil
+}
+
+// Paths counts the paths leading to purchases of a target in hourly sketches.
+type Paths struct {
+	topk.Hourly
+}
+
+type PathsCodec struct{}
+
+func (c *PathsCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *PathsCodec) Decode(data []byte) (interface{}, error) {
+	var p Paths
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return p, nil
+}
+
+type PathRow struct {
+	Steps []string       `json:"steps"`
+	Count AggregateValue `json:"count"`
+}
+
+type PathsResponse struct {
+	CategoryID string    `json:"category_id,omitempty"`
+	BrandID    string    `json:"brand_id,omitempty"`
+	Length     int       `json:"length"`
+	Paths      []PathRow `json:"paths"`
+}
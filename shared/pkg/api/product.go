@@ -4,5 +4,19 @@ type Product struct {
 	ProductID  uint64 `json:"product_id"`
 	BrandID    string `json:"brand_id"`
 	CategoryID string `json:"category_id"`
-	Price      int32  `json:"price"`
+	// Price is given in the minor units of Currency, e.g. cents.
+	Price int32 `json:"price"`
+	// Currency is the ISO 4217 code of the price's currency. Empty means the base currency of the exchange rates.
+	Currency string `json:"currency,omitempty"`
+	// BasePrice is the price in minor units of the base currency. It's set on the user tags emitted at ingest
+	// if exchange rates are configured, and isn't kept in user profiles. REFUNDs are converted at the rates of their ingest.
+	BasePrice *int64 `json:"base_price,omitempty"`
+}
+
+// NormalizedPrice returns the price in minor units of the base currency, or the price as it is if it hasn't been converted.
+func (p Product) NormalizedPrice() int64 {
+	if p.BasePrice != nil {
+		return *p.BasePrice
+	}
+	return int64(p.Price)
 }
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SegmentMemberKey returns the key of a segment's member in the segment table.
+// Keys of a segment's members share a prefix, so they can be listed with a range iterator.
+func SegmentMemberKey(segment string, cookie string) string {
+	return segment + "/" + cookie
+}
+
+// SplitSegmentMemberKey returns the segment and the cookie of a segment table key.
+func SplitSegmentMemberKey(key string) (string, string) {
+	segment, cookie, _ := strings.Cut(key, "/")
+	return segment, cookie
+}
+
+// SegmentEvent reports a cookie entering or exiting a segment.
+// Events of cookies which stay in a segment for longer than it was known before are reported as entering it again.
+type SegmentEvent struct {
+	Segment string    `json:"segment"`
+	Cookie  string    `json:"cookie"`
+	Entered bool      `json:"entered"`
+	Time    time.Time `json:"time"`
+	// Until is when the cookie leaves the segment unless it's active in the meantime. Zero means it never does.
+	Until time.Time `json:"until"`
+}
+
+type SegmentEventCodec struct{}
+
+func (c *SegmentEventCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *SegmentEventCodec) Decode(data []byte) (interface{}, error) {
+	var se SegmentEvent
+	err := json.Unmarshal(data, &se)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return se, nil
+}
+
+// SegmentMember is a cookie's membership in a segment.
+// Idle cookies aren't removed once their membership ends, so Active has to be checked when the members are read.
+type SegmentMember struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+// Active reports whether the membership lasts at t.
+func (sm *SegmentMember) Active(t time.Time) bool {
+	return sm.Until.IsZero() || sm.Until.After(t)
+}
+
+type SegmentMemberCodec struct{}
+
+func (c *SegmentMemberCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *SegmentMemberCodec) Decode(data []byte) (interface{}, error) {
+	var sm SegmentMember
+	err := json.Unmarshal(data, &sm)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return sm, nil
+}
+
+type UserSegmentsResponse struct {
+	Cookie   string   `json:"cookie"`
+	Segments []string `json:"segments"`
+}
+
+type SegmentMemberRow struct {
+	Cookie string    `json:"cookie"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+type SegmentMembersResponse struct {
+	Segment string             `json:"segment"`
+	Members []SegmentMemberRow `json:"members"`
+}
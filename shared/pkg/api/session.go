@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Session groups consecutive user tags of a cookie on a single device
+// which aren't separated by more than the inactivity gap.
+type Session struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Device  Device    `json:"device"`
+	Views   int64     `json:"views"`
+	Buys    int64     `json:"buys"`
+	Revenue int64     `json:"revenue"`
+}
+
+// SessionCodec encodes the current session of a cookie kept by the session processor.
+type SessionCodec struct{}
+
+func (c *SessionCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *SessionCodec) Decode(data []byte) (interface{}, error) {
+	var s Session
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return s, nil
+}
+
+type UserSessionsResponse struct {
+	Cookie   string    `json:"cookie"`
+	Sessions []Session `json:"sessions"`
+}
+
+// SessionEvent is emitted for the bucket a session started in,
+// either when the session starts or when it's extended by Extension.
+type SessionEvent struct {
+	Started   bool          `json:"started,omitempty"`
+	Extension time.Duration `json:"extension,omitempty"`
+}
+
+type SessionEventCodec struct{}
+
+func (c *SessionEventCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *SessionEventCodec) Decode(data []byte) (interface{}, error) {
+	var se SessionEvent
+	err := json.Unmarshal(data, &se)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return se, nil
+}
+
+// SessionAggregates sums up the sessions which started in a bucket.
+type SessionAggregates struct {
+	Count       int64         `json:"count"`
+	TotalLength time.Duration `json:"total_length"`
+}
+
+type SessionAggregatesCodec struct{}
+
+func (c *SessionAggregatesCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *SessionAggregatesCodec) Decode(data []byte) (interface{}, error) {
+	var sa SessionAggregates
+	err := json.Unmarshal(data, &sa)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return sa, nil
+}
+
+type SessionAggregatesRow struct {
+	Bucket BucketTime     `json:"1m_bucket"`
+	Count  AggregateValue `json:"sessions"`
+	// AvgLength is the average length of the bucket's sessions in seconds.
+	AvgLength string `json:"avg_session_length"`
+}
+
+type SessionAggregatesResponse struct {
+	Rows []SessionAggregatesRow `json:"rows"`
+}
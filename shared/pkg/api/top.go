@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+)
+
+// TopDimensions lists the dimensions whose heavy hitters the collector tracks in each bucket.
+var TopDimensions = []Dimension{
+	ProductDimension,
+	BrandDimension,
+	CategoryDimension,
+	CountryDimension,
+	RegionDimension,
+	MarketDimension,
+	LocalCurrencyDimension,
+}
+
+func ParseTopDimension(s string) (Dimension, error) {
+	for _, d := range TopDimensions {
+		if string(d.Column) == s {
+			return d, nil
+		}
+	}
+
+	return Dimension{}, fmt.Errorf("%q is not a valid top dimension", s)
+}
+
+type TopMetric string
+
+const (
+	TOP_COUNT     TopMetric = "count"
+	TOP_SUM_PRICE TopMetric = "sum_price"
+)
+
+var TopMetrics = []TopMetric{TOP_COUNT, TOP_SUM_PRICE}
+
+func ParseTopMetric(s string) (TopMetric, error) {
+	for _, m := range TopMetrics {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+
+	return TopMetric(""), fmt.Errorf("%q is not a valid top metric", s)
+}
+
+// TopKey identifies the sketch of the given dimension and metric within UserAggregates.
+func TopKey(column AggregateColumn, metric TopMetric) string {
+	return fmt.Sprintf("%s/%s", column, metric)
+}
+
+type TopItem struct {
+	Value    string         `json:"value"`
+	Total    AggregateValue `json:"total"`
+	MaxError AggregateValue `json:"max_error"`
+}
+
+type TopResponse struct {
+	Action    Action          `json:"action"`
+	Dimension AggregateColumn `json:"dimension"`
+	Metric    TopMetric       `json:"metric"`
+	Items     []TopItem       `json:"items"`
+}
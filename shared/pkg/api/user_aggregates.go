@@ -3,11 +3,28 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/topk"
 )
 
 type UserAggregates struct {
-	Count    int64 `json:"count" as:"count"`
+	Count int64 `json:"count" as:"count"`
+	// SumPrice is given in minor units of the base currency.
 	SumPrice int64 `json:"sum_price" as:"sum_price"`
+	// SumPriceByCurrency holds the sums of prices in minor units of their original currencies keyed by the currencies.
+	SumPriceByCurrency map[string]int64 `json:"sum_price_by_currency,omitempty" as:"-"`
+
+	// LateCount and LateSumPrice aggregate user tags that arrived behind the watermark.
+	LateCount              int64            `json:"late_count,omitempty" as:"late_count"`
+	LateSumPrice           int64            `json:"late_sum_price,omitempty" as:"late_sum_price"`
+	LateSumPriceByCurrency map[string]int64 `json:"late_sum_price_by_currency,omitempty" as:"-"`
+
+	// LatePurchases and DroppedPurchases hold the PurchaseKeys of BUYs which were counted as late or dropped,
+	// so that their REFUNDs are retracted from where the BUYs were counted.
+	LatePurchases    []string `json:"late_purchases,omitempty" as:"-"`
+	DroppedPurchases []string `json:"dropped_purchases,omitempty" as:"-"`
+
+	// TopK holds heavy hitter sketches keyed by TopKey. They're only maintained for unfiltered aggregates.
+	TopK map[string]*topk.Sketch `json:"top_k,omitempty" as:"-"`
 }
 
 type UserAggregatesCodec struct{}
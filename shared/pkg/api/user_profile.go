@@ -1,6 +1,55 @@
 package api
 
+import "time"
+
 type UserProfile struct {
 	Views []UserTag `json:"views"`
 	Buys  []UserTag `json:"buys"`
+	// Actions holds the user tags of the registered actions other than VIEW and BUY keyed by the actions.
+	Actions map[string][]UserTag `json:"actions,omitempty"`
+	// Segments holds the sorted ids of the segments the profile belonged to when it was last updated.
+	Segments []string `json:"segments,omitempty"`
+	// Interests are updated with every user tag, including the ones which have already left Views and Buys.
+	Interests Interests `json:"interests"`
+	// Features holds the sliding-window counters of the defined features keyed by their names.
+	Features map[string]FeatureState `json:"features,omitempty"`
+}
+
+// Tags returns the user tags of the action kept in the profile.
+func (up *UserProfile) Tags(a Action) []UserTag {
+	switch a {
+	case VIEW:
+		return up.Views
+	case BUY:
+		return up.Buys
+	default:
+		return up.Actions[a.String()]
+	}
+}
+
+// SetTags replaces the user tags of the action kept in the profile.
+func (up *UserProfile) SetTags(a Action, uts []UserTag) {
+	switch a {
+	case VIEW:
+		up.Views = uts
+	case BUY:
+		up.Buys = uts
+	default:
+		if up.Actions == nil {
+			up.Actions = make(map[string][]UserTag)
+		}
+		up.Actions[a.String()] = uts
+	}
+}
+
+// InterestScores holds time-decayed scores keyed by brands or categories.
+// All scores are decayed to the same reference time, so they are only multiplied when it moves.
+type InterestScores struct {
+	Time   time.Time          `json:"time"`
+	Scores map[string]float64 `json:"scores,omitempty"`
+}
+
+type Interests struct {
+	Brands     InterestScores `json:"brands"`
+	Categories InterestScores `json:"categories"`
 }
@@ -1,6 +1,9 @@
 package api
 
 type UserProfileResponse struct {
-	Cookie string `json:"cookie"`
-	UserProfile
+	Cookie string    `json:"cookie"`
+	Views  []UserTag `json:"views"`
+	Buys   []UserTag `json:"buys"`
+	// Actions holds the user tags of the registered actions other than VIEW and BUY keyed by the actions.
+	Actions map[string][]UserTag `json:"actions,omitempty"`
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -14,6 +15,14 @@ type UserTag struct {
 	Action  Action    `json:"action"`
 	Origin  string    `json:"origin"`
 	Product Product   `json:"product_info"`
+	// PurchaseTime is the time of the refunded BUY of the same product. It's only set for REFUNDs.
+	PurchaseTime *time.Time `json:"purchase_time,omitempty"`
+	// Geo is set by the forwarder's enrichment, it's never part of ingested user tags.
+	Geo *Geo `json:"geo,omitempty"`
+	// Catalog is set by the forwarder's product catalog lookup, it's never part of ingested user tags either.
+	Catalog *CatalogProduct `json:"catalog,omitempty"`
+	// Dropped is set by the forwarder on late BUYs it drops, so that the collectors know not to retract their REFUNDs.
+	Dropped bool `json:"dropped,omitempty"`
 }
 
 func (ut *UserTag) UnmarshalJSON(data []byte) error {
@@ -21,7 +30,9 @@ func (ut *UserTag) UnmarshalJSON(data []byte) error {
 
 	type Alias UserTag
 	aux := &struct {
-		Time string `json:"time"`
+		Time         string  `json:"time"`
+		Action       string  `json:"action"`
+		PurchaseTime *string `json:"purchase_time"`
 		*Alias
 	}{
 		Alias: (*Alias)(ut),
@@ -37,6 +48,21 @@ func (ut *UserTag) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("can't parse time: %w", err)
 	}
 
+	ut.PurchaseTime = nil
+	if aux.PurchaseTime != nil {
+		t, err := ParseDatetimeWithZone(*aux.PurchaseTime)
+		if err != nil {
+			return fmt.Errorf("can't parse purchase time: %w", err)
+		}
+		ut.PurchaseTime = &t
+	}
+
+	// The action is parsed last, so that user tags of unknown actions are decoded otherwise completely.
+	ut.Action, err = ParseAction(aux.Action)
+	if err != nil {
+		return fmt.Errorf("can't parse action: %w", err)
+	}
+
 	return nil
 }
 
@@ -51,9 +77,29 @@ func (utc *UserTagCodec) Decode(data []byte) (interface{}, error) {
 
 	ut := UserTag{}
 	err = json.Unmarshal(data, &ut)
-	if err != nil {
+	// Components may not have registered all the actions the service accepts, which mustn't stop them from processing
+	// the rest. User tags of unknown actions are decoded with the zero action, which isn't Known.
+	if err != nil && !errors.Is(err, ErrUnknownAction) {
 		return nil, fmt.Errorf("can't unmarshal data: %w", err)
 	}
 
 	return &ut, nil
 }
+
+// Purchase returns the BUY the REFUND retracts as described by the REFUND itself.
+func (ut *UserTag) Purchase() UserTag {
+	p := *ut
+	p.Action = BUY
+	if ut.PurchaseTime != nil {
+		p.Time = *ut.PurchaseTime
+	}
+	p.PurchaseTime = nil
+
+	return p
+}
+
+// PurchaseKey identifies a BUY, or the BUY a REFUND retracts, among the user tags of an aggregate.
+func (ut *UserTag) PurchaseKey() string {
+	p := ut.Purchase()
+	return fmt.Sprintf("%s/%d/%d", p.Cookie, p.Time.UnixNano(), p.Product.ProductID)
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUserTagPurchase(t *testing.T) {
+	purchaseTime := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	ts := []struct {
+		name     string
+		data     string
+		expected UserTag
+	}{
+		{
+			name: "Purchase of a REFUND is a BUY at the purchase time",
+			data: `{"time": "2022-03-02T12:00:00.000Z", "cookie": "c", "country": "PL", "device": "PC", "action": "REFUND", "origin": "X", "product_info": {"product_id": 1, "brand_id": "Nike", "category_id": "SHOES", "price": 1000}, "purchase_time": "2022-03-01T10:00:00.000Z"}`,
+			expected: UserTag{
+				Time:    purchaseTime,
+				Cookie:  "c",
+				Country: "PL",
+				Device:  PC,
+				Action:  BUY,
+				Origin:  "X",
+				Product: Product{ProductID: 1, BrandID: "Nike", CategoryID: "SHOES", Price: 1000},
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			var ut UserTag
+			err := json.Unmarshal([]byte(test.data), &ut)
+			if err != nil {
+				t.Fatalf("can't unmarshal user tag: %v", err)
+			}
+			if ut.PurchaseTime == nil || !ut.PurchaseTime.Equal(purchaseTime) {
+				t.Fatalf("expected purchase time %v, got %v", purchaseTime, ut.PurchaseTime)
+			}
+
+			p := ut.Purchase()
+			if !reflect.DeepEqual(test.expected, p) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, p)
+			}
+		})
+	}
+}
+
+func TestUserTagPurchaseKey(t *testing.T) {
+	purchaseTime := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	buy := UserTag{Time: purchaseTime, Cookie: "c", Action: BUY, Product: Product{ProductID: 1}}
+
+	ts := []struct {
+		name     string
+		ut       UserTag
+		expected bool
+	}{
+		{
+			name:     "REFUND has the key of its BUY",
+			ut:       UserTag{Time: purchaseTime.Add(time.Hour), Cookie: "c", Action: REFUND, Product: Product{ProductID: 1}, PurchaseTime: &purchaseTime},
+			expected: true,
+		},
+		{
+			name: "REFUND of another product has another key",
+			ut:   UserTag{Time: purchaseTime.Add(time.Hour), Cookie: "c", Action: REFUND, Product: Product{ProductID: 2}, PurchaseTime: &purchaseTime},
+		},
+		{
+			name: "BUY at another time has another key",
+			ut:   UserTag{Time: purchaseTime.Add(time.Millisecond), Cookie: "c", Action: BUY, Product: Product{ProductID: 1}},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res := test.ut.PurchaseKey() == buy.PurchaseKey()
+			if test.expected != res {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestUserTagCodecDecode(t *testing.T) {
+	ts := []struct {
+		name          string
+		data          string
+		expectedErr   bool
+		expectedKnown bool
+	}{
+		{
+			name:          "User tag of a known action is decoded",
+			data:          `{"time": "2022-03-01T10:00:00.000Z", "cookie": "c", "action": "VIEW"}`,
+			expectedKnown: true,
+		},
+		{
+			name: "User tag of an unknown action is decoded with the zero action",
+			data: `{"time": "2022-03-01T10:00:00.000Z", "cookie": "c", "action": "NOT_REGISTERED"}`,
+		},
+		{
+			name:        "Invalid user tag isn't decoded",
+			data:        `{"time": "yesterday", "cookie": "c", "action": "VIEW"}`,
+			expectedErr: true,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := new(UserTagCodec).Decode([]byte(test.data))
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("can't decode user tag: %v", err)
+			}
+
+			ut := v.(*UserTag)
+			if ut.Cookie != "c" || ut.Action.Known() != test.expectedKnown {
+				t.Errorf("expected and computed results differ: %v, %v", test.expectedKnown, ut)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// maxClockCatchUp bounds the number of closed buckets returned at once, e.g. after an evaluator has been stopped for a while.
+const maxClockCatchUp = time.Hour
+
+// Watermark is the event time progress reported by a single partition of a processor.
+type Watermark struct {
+	Partition int32     `json:"partition"`
+	Time      time.Time `json:"time"`
+}
+
+type WatermarkCodec struct{}
+
+func (c *WatermarkCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *WatermarkCodec) Decode(data []byte) (interface{}, error) {
+	var w Watermark
+	err := json.Unmarshal(data, &w)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return w, nil
+}
+
+// Watermarks holds the latest reported watermark of each partition of a processor.
+type Watermarks struct {
+	Partitions map[int32]time.Time `json:"partitions"`
+}
+
+// Min returns the watermark of the processor as a whole, i.e. the watermark of its slowest partition.
+func (ws Watermarks) Min() time.Time {
+	var res time.Time
+	for _, t := range ws.Partitions {
+		if res.IsZero() || t.Before(res) {
+			res = t
+		}
+	}
+
+	return res
+}
+
+type WatermarksCodec struct{}
+
+func (c *WatermarksCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *WatermarksCodec) Decode(data []byte) (interface{}, error) {
+	var ws Watermarks
+	err := json.Unmarshal(data, &ws)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	return ws, nil
+}
+
+// BucketClock follows the watermarks of a processor and tells which buckets have closed since it last advanced.
+type BucketClock struct {
+	Watermarks Watermarks `json:"watermarks"`
+	// LastClosed is the start of the most recent closed bucket.
+	LastClosed time.Time `json:"last_closed"`
+}
+
+// Advance folds w into the watermarks and returns the buckets which closed, in ascending order.
+// Buckets are held back by delay to let the processor's table changelog catch up.
+func (c *BucketClock) Advance(w Watermark, delay time.Duration) []time.Time {
+	if c.Watermarks.Partitions == nil {
+		c.Watermarks.Partitions = make(map[int32]time.Time)
+	}
+
+	if w.Time.After(c.Watermarks.Partitions[w.Partition]) {
+		c.Watermarks.Partitions[w.Partition] = w.Time
+	}
+
+	// Buckets ending at or before end are complete. A fresh or far behind clock only closes the latest of them.
+	end := c.Watermarks.Min().Add(-delay).Truncate(time.Minute)
+	if c.LastClosed.IsZero() || end.Sub(c.LastClosed) > maxClockCatchUp {
+		c.LastClosed = end.Add(-2 * time.Minute)
+	}
+
+	res := make([]time.Time, 0)
+	for b := c.LastClosed.Add(time.Minute); !b.Add(time.Minute).After(end); b = b.Add(time.Minute) {
+		res = append(res, b)
+		c.LastClosed = b
+	}
+
+	return res
+}
+
+type WatermarkResponse struct {
+	Watermark  time.Time           `json:"watermark"`
+	Partitions map[int32]time.Time `json:"partitions"`
+}
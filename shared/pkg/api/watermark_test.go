@@ -0,0 +1,74 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketClockAdvance(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	c := BucketClock{}
+
+	res := c.Advance(Watermark{Partition: 0, Time: base.Add(90 * time.Second)}, 0)
+	expected := []time.Time{base}
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected and closed buckets differ: %v != %v", expected, res)
+	}
+
+	res = c.Advance(Watermark{Partition: 1, Time: base.Add(5 * time.Minute)}, 0)
+	expected = []time.Time{}
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected no buckets to close before the slowest partition advances, got %v", res)
+	}
+
+	res = c.Advance(Watermark{Partition: 0, Time: base.Add(4 * time.Minute)}, time.Minute)
+	expected = []time.Time{base.Add(time.Minute), base.Add(2 * time.Minute)}
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected and closed buckets differ: %v != %v", expected, res)
+	}
+}
+
+func TestBucketClockCatchUp(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	ts := []struct {
+		name       string
+		watermarks []time.Time
+		// expected holds the buckets closed by the last watermark
+		expected []time.Time
+	}{
+		{
+			name:       "First watermark closes the bucket before it",
+			watermarks: []time.Time{base.Add(90 * time.Second)},
+			expected:   []time.Time{base},
+		},
+		{
+			name:       "Short gap closes every bucket in between",
+			watermarks: []time.Time{base.Add(90 * time.Second), base.Add(4*time.Minute + 30*time.Second)},
+			expected:   []time.Time{base.Add(time.Minute), base.Add(2 * time.Minute), base.Add(3 * time.Minute)},
+		},
+		{
+			name:       "Gap longer than the catch-up closes only the latest bucket",
+			watermarks: []time.Time{base.Add(90 * time.Second), base.Add(3*time.Hour + 30*time.Second)},
+			expected:   []time.Time{base.Add(3*time.Hour - time.Minute)},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			c := BucketClock{}
+
+			var res []time.Time
+			for _, w := range test.watermarks {
+				res = c.Advance(Watermark{Partition: 0, Time: w}, 0)
+			}
+
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package category
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+)
+
+// Hierarchy maps categories to their parent categories. Categories missing from it are roots.
+type Hierarchy map[string]string
+
+type category struct {
+	ID     string `json:"id"`
+	Parent string `json:"parent"`
+}
+
+type hierarchyFile struct {
+	Categories []category `json:"categories"`
+}
+
+// Parse reads a hierarchy from JSON listing the categories with their parents, e.g.
+//
+//	{"categories": [{"id": "WOMEN", "parent": "FASHION"}, {"id": "WOMEN_SHOES", "parent": "WOMEN"}]}
+func Parse(data []byte) (Hierarchy, error) {
+	var hf hierarchyFile
+	err := json.Unmarshal(data, &hf)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal hierarchy: %w", err)
+	}
+
+	h := make(Hierarchy, len(hf.Categories))
+	for _, c := range hf.Categories {
+		if len(c.ID) == 0 || len(c.Parent) == 0 {
+			return nil, errors.New("categories have to have an id and a parent")
+		}
+		if _, ok := h[c.ID]; ok {
+			return nil, fmt.Errorf("duplicate category %q", c.ID)
+		}
+		h[c.ID] = c.Parent
+	}
+
+	for id := range h {
+		// A path longer than the number of categories has to visit one of them twice.
+		if len(h.Ancestors(id)) > len(h) {
+			return nil, fmt.Errorf("category %q is its own ancestor", id)
+		}
+	}
+
+	return h, nil
+}
+
+// Ancestors returns the ancestors of the category starting with its parent.
+func (h Hierarchy) Ancestors(id string) []string {
+	ancestors := make([]string, 0)
+	for parent, ok := h[id]; ok; parent, ok = h[parent] {
+		ancestors = append(ancestors, parent)
+		// Parse relies on cycles being cut off.
+		if len(ancestors) > len(h) {
+			break
+		}
+	}
+
+	return ancestors
+}
+
+// RolledUp reports whether aggregates filtered by the columns are kept for ancestor categories too,
+// i.e. whether the columns are the category and optionally the brand. Rolling up any other subsets would multiply
+// the keys every user tag is aggregated under by the depth of the hierarchy.
+func RolledUp(columns []api.AggregateColumn) bool {
+	hasCategory := false
+	for _, c := range columns {
+		switch c {
+		case api.CategoryDimension.Column:
+			hasCategory = true
+		case api.BrandDimension.Column:
+		default:
+			return false
+		}
+	}
+
+	return hasCategory
+}
+
+// HasChildren reports whether the category is the parent of any other.
+func (h Hierarchy) HasChildren(id string) bool {
+	for _, parent := range h {
+		if parent == id {
+			return true
+		}
+	}
+
+	return false
+}
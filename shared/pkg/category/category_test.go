@@ -0,0 +1,136 @@
+package category
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	ts := []struct {
+		name        string
+		data        string
+		expected    Hierarchy
+		expectedErr bool
+	}{
+		{
+			name: "Categories are mapped to their parents",
+			data: `{"categories": [{"id": "WOMEN", "parent": "FASHION"}, {"id": "WOMEN_SHOES", "parent": "WOMEN"}]}`,
+			expected: Hierarchy{
+				"WOMEN":       "FASHION",
+				"WOMEN_SHOES": "WOMEN",
+			},
+		},
+		{
+			name:        "Categories have to have parents",
+			data:        `{"categories": [{"id": "WOMEN"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Categories can't be duplicated",
+			data:        `{"categories": [{"id": "WOMEN", "parent": "FASHION"}, {"id": "WOMEN", "parent": "CLOTHING"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Categories can't be their own ancestors",
+			data:        `{"categories": [{"id": "WOMEN", "parent": "WOMEN_SHOES"}, {"id": "WOMEN_SHOES", "parent": "WOMEN"}]}`,
+			expectedErr: true,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := Parse([]byte(test.data))
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("can't parse hierarchy: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.expected, h) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, h)
+			}
+		})
+	}
+}
+
+func TestAncestors(t *testing.T) {
+	h := Hierarchy{
+		"WOMEN":       "FASHION",
+		"WOMEN_SHOES": "WOMEN",
+	}
+
+	ts := []struct {
+		name     string
+		id       string
+		expected []string
+	}{
+		{
+			name:     "Ancestors start with the parent",
+			id:       "WOMEN_SHOES",
+			expected: []string{"WOMEN", "FASHION"},
+		},
+		{
+			name:     "Roots have no ancestors",
+			id:       "FASHION",
+			expected: []string{},
+		},
+		{
+			name:     "Unknown categories have no ancestors",
+			id:       "TOYS",
+			expected: []string{},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			ancestors := h.Ancestors(test.id)
+			if !reflect.DeepEqual(test.expected, ancestors) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, ancestors)
+			}
+		})
+	}
+}
+
+func TestRolledUp(t *testing.T) {
+	ts := []struct {
+		name     string
+		columns  []api.AggregateColumn
+		expected bool
+	}{
+		{
+			name:     "Category alone is rolled up",
+			columns:  []api.AggregateColumn{api.CategoryDimension.Column},
+			expected: true,
+		},
+		{
+			name:     "Category with the brand is rolled up",
+			columns:  []api.AggregateColumn{api.BrandDimension.Column, api.CategoryDimension.Column},
+			expected: true,
+		},
+		{
+			name:    "Category with other dimensions isn't rolled up",
+			columns: []api.AggregateColumn{api.CategoryDimension.Column, api.CountryDimension.Column},
+		},
+		{
+			name:    "Brand alone isn't rolled up",
+			columns: []api.AggregateColumn{api.BrandDimension.Column},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res := RolledUp(test.columns)
+			if test.expected != res {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, res)
+			}
+		})
+	}
+}
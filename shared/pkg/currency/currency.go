@@ -0,0 +1,84 @@
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Rate describes a currency's minor units and its value in the base currency.
+type Rate struct {
+	Currency string `json:"currency"`
+	// Exponent is the number of digits of the minor units, e.g. 2 for cents.
+	Exponent int `json:"exponent"`
+	// Rate is the value of a unit of the currency in units of the base currency.
+	Rate float64 `json:"rate"`
+}
+
+// Rates holds the exchange rates prices are normalized with.
+type Rates struct {
+	Base       string `json:"base"`
+	Currencies []Rate `json:"currencies"`
+
+	rates map[string]Rate
+}
+
+// Parse reads the exchange rates from JSON listing them along with the base currency, e.g.
+//
+//	{"base": "USD", "currencies": [{"currency": "USD", "exponent": 2, "rate": 1}, {"currency": "PLN", "exponent": 2, "rate": 0.25}]}
+func Parse(data []byte) (*Rates, error) {
+	var r Rates
+	err := json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal exchange rates: %w", err)
+	}
+
+	r.rates = make(map[string]Rate, len(r.Currencies))
+	for _, c := range r.Currencies {
+		if len(c.Currency) == 0 {
+			return nil, errors.New("currency is missing")
+		}
+		if _, ok := r.rates[c.Currency]; ok {
+			return nil, fmt.Errorf("duplicate currency %q", c.Currency)
+		}
+		if c.Exponent < 0 || c.Exponent > 4 {
+			return nil, fmt.Errorf("exponent of %q has to be within [0, 4]", c.Currency)
+		}
+		if c.Rate <= 0 {
+			return nil, fmt.Errorf("rate of %q has to be positive", c.Currency)
+		}
+		r.rates[c.Currency] = c
+	}
+
+	base, ok := r.rates[r.Base]
+	if !ok {
+		return nil, fmt.Errorf("base currency %q is missing", r.Base)
+	}
+	if base.Rate != 1 {
+		return nil, fmt.Errorf("rate of the base currency has to be 1")
+	}
+
+	return &r, nil
+}
+
+// Currency returns the currency of a price, i.e. the base currency if it's empty.
+func (r *Rates) Currency(currency string) string {
+	if len(currency) == 0 {
+		return r.Base
+	}
+	return currency
+}
+
+// ToBase converts an amount in minor units of the currency to minor units of the base currency.
+// It reports false if the currency has no exchange rate.
+func (r *Rates) ToBase(amount int64, currency string) (int64, bool) {
+	from, ok := r.rates[r.Currency(currency)]
+	if !ok {
+		return 0, false
+	}
+	to := r.rates[r.Base]
+
+	v := float64(amount) * from.Rate * math.Pow10(to.Exponent-from.Exponent)
+	return int64(math.Round(v)), true
+}
@@ -0,0 +1,103 @@
+package currency
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	ts := []struct {
+		name        string
+		data        string
+		expectedErr bool
+	}{
+		{
+			name: "Rates with the base currency are parsed",
+			data: `{"base": "USD", "currencies": [{"currency": "USD", "exponent": 2, "rate": 1}, {"currency": "JPY", "exponent": 0, "rate": 0.0067}]}`,
+		},
+		{
+			name:        "Base currency has to have a rate",
+			data:        `{"base": "USD", "currencies": [{"currency": "EUR", "exponent": 2, "rate": 1}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Rate of the base currency has to be 1",
+			data:        `{"base": "USD", "currencies": [{"currency": "USD", "exponent": 2, "rate": 2}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Currencies can't be duplicated",
+			data:        `{"base": "USD", "currencies": [{"currency": "USD", "exponent": 2, "rate": 1}, {"currency": "USD", "exponent": 2, "rate": 1}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Rates have to be positive",
+			data:        `{"base": "USD", "currencies": [{"currency": "USD", "exponent": 2, "rate": 1}, {"currency": "EUR", "exponent": 2, "rate": 0}]}`,
+			expectedErr: true,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.data))
+			if test.expectedErr != (err != nil) {
+				t.Errorf("expected error: %v, got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestToBase(t *testing.T) {
+	r, err := Parse([]byte(`{"base": "USD", "currencies": [
+		{"currency": "USD", "exponent": 2, "rate": 1},
+		{"currency": "PLN", "exponent": 2, "rate": 0.25},
+		{"currency": "JPY", "exponent": 0, "rate": 0.0067}
+	]}`))
+	if err != nil {
+		t.Fatalf("can't parse exchange rates: %v", err)
+	}
+
+	ts := []struct {
+		name       string
+		amount     int64
+		currency   string
+		expected   int64
+		expectedOk bool
+	}{
+		{
+			name:       "Prices without a currency are in the base currency",
+			amount:     1234,
+			expected:   1234,
+			expectedOk: true,
+		},
+		{
+			name:       "Prices are converted with the rate",
+			amount:     1000,
+			currency:   "PLN",
+			expected:   250,
+			expectedOk: true,
+		},
+		{
+			name:       "Prices are converted between minor units",
+			amount:     1500,
+			currency:   "JPY",
+			expected:   1005,
+			expectedOk: true,
+		},
+		{
+			name:     "Currencies without a rate can't be converted",
+			amount:   1000,
+			currency: "EUR",
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			v, ok := r.ToBase(test.amount, test.currency)
+			if v != test.expected || ok != test.expectedOk {
+				t.Errorf("expected and computed results differ: %d, %v != %d, %v", test.expected, test.expectedOk, v, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package feature
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/segment"
+	"os"
+	"sort"
+	"time"
+)
+
+// Aggregate is the value a feature's counter accumulates over its window.
+type Aggregate string
+
+const (
+	COUNT     Aggregate = "count"
+	SUM_PRICE Aggregate = "sum_price"
+	// DISTINCT counts the distinct values of a field.
+	DISTINCT Aggregate = "distinct"
+)
+
+// Slices is the number of buckets a window is split into. Windows slide by a bucket at a time.
+const Slices = 12
+
+// Definition describes a sliding-window counter kept for every cookie.
+type Definition struct {
+	Name string `json:"name"`
+	// Filter is a segment rule selecting the counted user tags. Empty means all of them.
+	Filter    string    `json:"filter,omitempty"`
+	Aggregate Aggregate `json:"aggregate"`
+	// Field is the dimension whose values distinct counters count.
+	Field  string `json:"field,omitempty"`
+	Window string `json:"window"`
+
+	match   segment.Predicate
+	extract func(ut *api.UserTag) string
+	window  time.Duration
+	width   time.Duration
+}
+
+type definitionsFile struct {
+	Features []Definition `json:"features"`
+}
+
+// Load reads and validates the feature definitions file.
+func Load(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read features file: %w", err)
+	}
+
+	var df definitionsFile
+	err = json.Unmarshal(data, &df)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal features file: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range df.Features {
+		err = df.Features[i].Init()
+		if err != nil {
+			return nil, fmt.Errorf("invalid feature %q: %w", df.Features[i].Name, err)
+		}
+
+		if names[df.Features[i].Name] {
+			return nil, fmt.Errorf("duplicate feature %q", df.Features[i].Name)
+		}
+		names[df.Features[i].Name] = true
+	}
+
+	return df.Features, nil
+}
+
+// Init validates the definition and prepares its filter.
+func (d *Definition) Init() error {
+	var err error
+
+	if len(d.Name) == 0 {
+		return errors.New("name is missing")
+	}
+
+	d.match = func(ut *api.UserTag) bool { return true }
+	if len(d.Filter) > 0 {
+		d.match, err = segment.Parse(d.Filter)
+		if err != nil {
+			return fmt.Errorf("can't parse filter: %w", err)
+		}
+	}
+
+	switch d.Aggregate {
+	case COUNT, SUM_PRICE:
+		if len(d.Field) > 0 {
+			return fmt.Errorf("field doesn't apply to %q aggregates", d.Aggregate)
+		}
+	case DISTINCT:
+		for _, dim := range api.Dimensions {
+			if !dim.Derived && string(dim.Column) == d.Field {
+				d.extract = dim.Extract
+			}
+		}
+		if string(api.ProductDimension.Column) == d.Field {
+			d.extract = api.ProductDimension.Extract
+		}
+		if d.extract == nil {
+			return fmt.Errorf("%q is not a valid field", d.Field)
+		}
+	default:
+		return fmt.Errorf("%q is not a valid aggregate", d.Aggregate)
+	}
+
+	d.window, err = time.ParseDuration(d.Window)
+	if err != nil {
+		return fmt.Errorf("can't parse window: %w", err)
+	}
+	d.width = d.window / Slices
+	if d.width < time.Second {
+		return errors.New("window has to be at least a second per slice")
+	}
+
+	return nil
+}
+
+// inWindow reports whether the bucket starting at start overlaps the window ending at end.
+func (d *Definition) inWindow(start time.Time, end time.Time) bool {
+	return start.Add(d.width).After(end.Add(-d.window))
+}
+
+// Add counts the user tag in the state of the feature.
+func (d *Definition) Add(fs *api.FeatureState, ut *api.UserTag) {
+	// Windows of all features end at the most recent user tag, whether they count it or not.
+	if ut.Time.After(fs.Time) {
+		fs.Time = ut.Time
+	}
+
+	// Buckets are sorted by their starts, so the expired ones are at the front.
+	expired := 0
+	for expired < len(fs.Buckets) && !d.inWindow(fs.Buckets[expired].Start, fs.Time) {
+		expired++
+	}
+	fs.Buckets = fs.Buckets[expired:]
+
+	start := ut.Time.Truncate(d.width)
+	if !d.match(ut) || !d.inWindow(start, fs.Time) {
+		return
+	}
+
+	i := sort.Search(len(fs.Buckets), func(i int) bool {
+		return !fs.Buckets[i].Start.Before(start)
+	})
+	if i == len(fs.Buckets) || !fs.Buckets[i].Start.Equal(start) {
+		fs.Buckets = append(fs.Buckets, api.FeatureBucket{})
+		copy(fs.Buckets[i+1:], fs.Buckets[i:])
+		fs.Buckets[i] = api.FeatureBucket{Start: start}
+	}
+	b := &fs.Buckets[i]
+
+	switch d.Aggregate {
+	case COUNT:
+		b.Sum += 1
+	case SUM_PRICE:
+		b.Sum += ut.Product.NormalizedPrice()
+	case DISTINCT:
+		v := d.extract(ut)
+		for _, x := range b.Values {
+			if x == v {
+				return
+			}
+		}
+		b.Values = append(b.Values, v)
+	}
+}
+
+// Remove retracts the BUY refunded by ut from the state of the feature if its bucket is still kept.
+// Distinct values aren't retracted, as they may have been counted for other user tags as well.
+func (d *Definition) Remove(fs *api.FeatureState, ut *api.UserTag) {
+	p := ut.Purchase()
+	if !d.match(&p) {
+		return
+	}
+
+	start := p.Time.Truncate(d.width)
+	i := sort.Search(len(fs.Buckets), func(i int) bool {
+		return !fs.Buckets[i].Start.Before(start)
+	})
+	if i == len(fs.Buckets) || !fs.Buckets[i].Start.Equal(start) {
+		return
+	}
+	b := &fs.Buckets[i]
+
+	switch d.Aggregate {
+	case COUNT:
+		b.Sum -= 1
+	case SUM_PRICE:
+		b.Sum -= p.Product.NormalizedPrice()
+	}
+}
+
+// Value returns the value of the feature over the window ending at end.
+func (d *Definition) Value(fs api.FeatureState, end time.Time) int64 {
+	var sum int64
+	distinct := make(map[string]bool)
+
+	for _, b := range fs.Buckets {
+		if !d.inWindow(b.Start, end) || b.Start.After(end) {
+			continue
+		}
+
+		sum += b.Sum
+		for _, v := range b.Values {
+			distinct[v] = true
+		}
+	}
+
+	if d.Aggregate == DISTINCT {
+		return int64(len(distinct))
+	}
+	return sum
+}
+
+// Update counts the user tag in the states of all features.
+func Update(states *map[string]api.FeatureState, defs []Definition, ut *api.UserTag) {
+	if *states == nil {
+		*states = make(map[string]api.FeatureState, len(defs))
+	}
+
+	for i := range defs {
+		fs := (*states)[defs[i].Name]
+		defs[i].Add(&fs, ut)
+		(*states)[defs[i].Name] = fs
+	}
+
+	dropUndefined(states, defs)
+}
+
+// Retract retracts the BUY refunded by ut from the states of all features.
+func Retract(states *map[string]api.FeatureState, defs []Definition, ut *api.UserTag) {
+	for i := range defs {
+		fs, ok := (*states)[defs[i].Name]
+		if !ok {
+			continue
+		}
+		defs[i].Remove(&fs, ut)
+		(*states)[defs[i].Name] = fs
+	}
+
+	dropUndefined(states, defs)
+}
+
+// dropUndefined drops the states of features which are no longer defined.
+func dropUndefined(states *map[string]api.FeatureState, defs []Definition) {
+	if len(*states) > len(defs) {
+		defined := make(map[string]bool, len(defs))
+		for i := range defs {
+			defined[defs[i].Name] = true
+		}
+		for name := range *states {
+			if !defined[name] {
+				delete(*states, name)
+			}
+		}
+	}
+}
+
+// Latest returns the time of the most recent user tag any of the counters has seen.
+func Latest(states map[string]api.FeatureState) time.Time {
+	var t time.Time
+	for _, fs := range states {
+		if fs.Time.After(t) {
+			t = fs.Time
+		}
+	}
+	return t
+}
+
+// Values returns the values of all features over the windows ending at end, keyed by their names.
+func Values(states map[string]api.FeatureState, defs []Definition, end time.Time) map[string]int64 {
+	res := make(map[string]int64, len(defs))
+	for i := range defs {
+		res[defs[i].Name] = defs[i].Value(states[defs[i].Name], end)
+	}
+	return res
+}
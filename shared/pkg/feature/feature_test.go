@@ -0,0 +1,120 @@
+package feature
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdate(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	tag := func(action api.Action, offset time.Duration, device api.Device, price int32) api.UserTag {
+		return api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Device:  device,
+			Product: api.Product{Price: price},
+		}
+	}
+
+	refund := func(offset time.Duration, purchaseOffset time.Duration, device api.Device, price int32) api.UserTag {
+		ut := tag(api.REFUND, offset, device, price)
+		purchaseTime := base.Add(purchaseOffset)
+		ut.PurchaseTime = &purchaseTime
+		return ut
+	}
+
+	defs := []Definition{
+		{Name: "views_5m", Filter: "action = VIEW", Aggregate: COUNT, Window: "5m"},
+		{Name: "views_1h", Filter: "action = VIEW", Aggregate: COUNT, Window: "1h"},
+		{Name: "spend_1h", Filter: "action = BUY", Aggregate: SUM_PRICE, Window: "1h"},
+		{Name: "devices_1h", Aggregate: DISTINCT, Field: "device", Window: "1h"},
+	}
+	for i := range defs {
+		err := defs[i].Init()
+		if err != nil {
+			t.Fatalf("can't init feature: %v", err)
+		}
+	}
+
+	ts := []struct {
+		name     string
+		tags     []api.UserTag
+		expected map[string]int64
+	}{
+		{
+			name: "No user tags",
+			tags: []api.UserTag{},
+			expected: map[string]int64{
+				"views_5m":   0,
+				"views_1h":   0,
+				"spend_1h":   0,
+				"devices_1h": 0,
+			},
+		},
+		{
+			name: "User tags leave shorter windows first",
+			tags: []api.UserTag{
+				tag(api.VIEW, 0, api.PC, 100),
+				tag(api.BUY, 10*time.Minute, api.MOBILE, 300),
+				tag(api.VIEW, 30*time.Minute, api.MOBILE, 100),
+				tag(api.VIEW, 32*time.Minute, api.PC, 100),
+			},
+			expected: map[string]int64{
+				"views_5m":   2,
+				"views_1h":   3,
+				"spend_1h":   300,
+				"devices_1h": 2,
+			},
+		},
+		{
+			name: "Late user tags are counted in their buckets",
+			tags: []api.UserTag{
+				tag(api.BUY, 50*time.Minute, api.TV, 200),
+				tag(api.BUY, 20*time.Minute, api.PC, 300),
+				tag(api.VIEW, 90*time.Minute, api.PC, 100),
+			},
+			expected: map[string]int64{
+				"views_5m":   1,
+				"views_1h":   1,
+				"spend_1h":   200,
+				"devices_1h": 2,
+			},
+		},
+		{
+			name: "Refunded BUYs are retracted from counters but not from distinct values",
+			tags: []api.UserTag{
+				tag(api.BUY, 10*time.Minute, api.MOBILE, 300),
+				tag(api.BUY, 20*time.Minute, api.PC, 200),
+				refund(25*time.Minute, 10*time.Minute, api.MOBILE, 300),
+			},
+			expected: map[string]int64{
+				"views_5m":   0,
+				"views_1h":   0,
+				"spend_1h":   200,
+				"devices_1h": 2,
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			var states map[string]api.FeatureState
+			for j := range test.tags {
+				if test.tags[j].Action == api.REFUND {
+					Retract(&states, defs, &test.tags[j])
+				} else {
+					Update(&states, defs, &test.tags[j])
+				}
+			}
+
+			computed := Values(states, defs, Latest(states))
+			if !reflect.DeepEqual(test.expected, computed) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, computed)
+			}
+		})
+	}
+}
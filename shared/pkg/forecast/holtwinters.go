@@ -0,0 +1,118 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+)
+
+// smoothingGrid holds the candidate values of the smoothing parameters the model is fitted over.
+var smoothingGrid = []float64{0.05, 0.1, 0.2, 0.3, 0.5, 0.7, 0.9}
+
+// Model is an additive Holt-Winters model, i.e. exponential smoothing of the level, trend and seasonal components of a series.
+type Model struct {
+	Alpha  float64
+	Beta   float64
+	Gamma  float64
+	Season int
+
+	level    float64
+	trend    float64
+	seasonal []float64
+	n        int
+	// sigma is the standard deviation of the one step ahead forecast errors.
+	sigma float64
+}
+
+// Fit picks the smoothing parameters minimising the squared one step ahead forecast errors of the series.
+// The series has to span at least two seasons.
+func Fit(ys []float64, season int) (*Model, error) {
+	if season <= 0 {
+		return nil, fmt.Errorf("season has to be positive")
+	}
+
+	if len(ys) < 2*season {
+		return nil, fmt.Errorf("series of length %d doesn't span two seasons of length %d", len(ys), season)
+	}
+
+	var best *Model
+	bestSSE := math.Inf(1)
+	for _, alpha := range smoothingGrid {
+		for _, beta := range smoothingGrid {
+			for _, gamma := range smoothingGrid {
+				m := &Model{Alpha: alpha, Beta: beta, Gamma: gamma, Season: season}
+				sse := m.smooth(ys)
+				if sse < bestSSE {
+					best, bestSSE = m, sse
+				}
+			}
+		}
+	}
+
+	return best, nil
+}
+
+// smooth runs the model over the series and returns the sum of squared one step ahead forecast errors.
+func (m *Model) smooth(ys []float64) float64 {
+	s := m.Season
+
+	var first, second float64
+	for i := 0; i < s; i++ {
+		first += ys[i]
+		second += ys[s+i]
+	}
+	first /= float64(s)
+	second /= float64(s)
+
+	// The initial components are estimated from the first two seasons, with the level at the end of the first one.
+	m.trend = (second - first) / float64(s)
+	m.level = first + m.trend*float64(s-1)/2
+	m.seasonal = make([]float64, s)
+	for i := 0; i < s; i++ {
+		m.seasonal[i] = (ys[i]-first+ys[s+i]-second)/2 - m.trend*(float64(i)-float64(s-1)/2)
+	}
+
+	var sse float64
+	for t := s; t < len(ys); t++ {
+		i := t % s
+
+		err := ys[t] - (m.level + m.trend + m.seasonal[i])
+		sse += err * err
+
+		level := m.Alpha*(ys[t]-m.seasonal[i]) + (1-m.Alpha)*(m.level+m.trend)
+		m.trend = m.Beta*(level-m.level) + (1-m.Beta)*m.trend
+		m.seasonal[i] = m.Gamma*(ys[t]-level) + (1-m.Gamma)*m.seasonal[i]
+		m.level = level
+	}
+
+	m.n = len(ys)
+	m.sigma = math.Sqrt(sse / float64(len(ys)-s))
+
+	return sse
+}
+
+// Forecast returns the prediction h steps past the end of the series.
+func (m *Model) Forecast(h int) float64 {
+	return m.level + float64(h)*m.trend + m.seasonal[(m.n+h-1)%m.Season]
+}
+
+// Stddev returns the standard deviation of the prediction h steps past the end of the series.
+func (m *Model) Stddev(h int) float64 {
+	v := 1.0
+	for j := 1; j < h; j++ {
+		c := m.Alpha * (1 + float64(j)*m.Beta)
+		if j%m.Season == 0 {
+			c += m.Gamma
+		}
+		v += c * c
+	}
+
+	return m.sigma * math.Sqrt(v)
+}
+
+// Interval returns the bounds of the prediction h steps past the end of the series at the given confidence level.
+func (m *Model) Interval(h int, confidence float64) (float64, float64) {
+	z := math.Sqrt2 * math.Erfinv(confidence)
+	f, d := m.Forecast(h), z*m.Stddev(h)
+
+	return f - d, f + d
+}
@@ -0,0 +1,76 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	pattern := []float64{10, 20, 30, 20}
+
+	ts := []struct {
+		name   string
+		season int
+		ys     func(t int) float64
+	}{
+		{
+			name:   "Seasonal series is continued",
+			season: len(pattern),
+			ys: func(t int) float64 {
+				return 100 + pattern[t%len(pattern)]
+			},
+		},
+		{
+			name:   "Trend is continued",
+			season: len(pattern),
+			ys: func(t int) float64 {
+				return 100 + 2*float64(t) + pattern[t%len(pattern)]
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			n := 5 * test.season
+			ys := make([]float64, n)
+			for i := range ys {
+				ys[i] = test.ys(i)
+			}
+
+			m, err := Fit(ys, test.season)
+			if err != nil {
+				t.Fatalf("can't fit model: %v", err)
+			}
+
+			for h := 1; h <= 2*test.season; h++ {
+				expected := test.ys(n + h - 1)
+				res := m.Forecast(h)
+				if math.Abs(expected-res) > 1e-6 {
+					t.Errorf("expected and forecast values %d steps ahead differ: %v != %v", h, expected, res)
+				}
+			}
+		})
+	}
+}
+
+func TestFitTooShort(t *testing.T) {
+	_, err := Fit([]float64{1, 2, 3}, 2)
+	if err == nil {
+		t.Errorf("expected an error for a series shorter than two seasons")
+	}
+}
+
+func TestInterval(t *testing.T) {
+	m := &Model{Alpha: 0.5, Beta: 0.1, Gamma: 0.1, Season: 2, level: 10, seasonal: []float64{0, 0}, n: 4, sigma: 2}
+
+	lower, upper := m.Interval(1, 0.95)
+	if math.Abs(lower-(10-1.96*2)) > 0.01 || math.Abs(upper-(10+1.96*2)) > 0.01 {
+		t.Errorf("unexpected 95%% interval: [%v, %v]", lower, upper)
+	}
+
+	lower2, upper2 := m.Interval(3, 0.95)
+	if upper2-lower2 <= upper-lower {
+		t.Errorf("expected the interval to widen with the horizon: [%v, %v] vs [%v, %v]", lower2, upper2, lower, upper)
+	}
+}
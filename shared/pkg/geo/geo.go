@@ -0,0 +1,64 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"io"
+)
+
+// Reference maps countries to the dimensions derived from them.
+type Reference map[string]api.Geo
+
+var header = []string{"country", "region", "market", "currency"}
+
+// Parse reads a reference from CSV with the columns listed in its header: country, region, market and currency.
+func Parse(data []byte) (Reference, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = len(header)
+	r.TrimLeadingSpace = true
+
+	h, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can't read header: %w", err)
+	}
+	for i := range header {
+		if h[i] != header[i] {
+			return nil, fmt.Errorf("expected column %q but got %q", header[i], h[i])
+		}
+	}
+
+	ref := make(Reference)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't read record: %w", err)
+		}
+
+		if _, ok := ref[record[0]]; ok {
+			return nil, fmt.Errorf("duplicate country %q", record[0])
+		}
+		ref[record[0]] = api.Geo{
+			Region:   record[1],
+			Market:   record[2],
+			Currency: record[3],
+		}
+	}
+
+	return ref, nil
+}
+
+// Lookup returns the dimensions derived from the country. Countries missing from the reference have none.
+func (ref Reference) Lookup(country string) *api.Geo {
+	g, ok := ref[country]
+	if !ok {
+		return nil
+	}
+
+	return &g
+}
@@ -0,0 +1,67 @@
+package geo
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	ts := []struct {
+		name        string
+		data        string
+		expected    Reference
+		expectedErr bool
+	}{
+		{
+			name: "Countries are mapped to their dimensions",
+			data: "country,region,market,currency\nPL,EUROPE,CEE,PLN\nUS, AMERICAS, NORTH_AMERICA, USD\n",
+			expected: Reference{
+				"PL": {Region: "EUROPE", Market: "CEE", Currency: "PLN"},
+				"US": {Region: "AMERICAS", Market: "NORTH_AMERICA", Currency: "USD"},
+			},
+		},
+		{
+			name:        "Header has to list the columns",
+			data:        "country,market,region,currency\nPL,CEE,EUROPE,PLN\n",
+			expectedErr: true,
+		},
+		{
+			name:        "Records have to have all columns",
+			data:        "country,region,market,currency\nPL,EUROPE,CEE\n",
+			expectedErr: true,
+		},
+		{
+			name:        "Countries can't be duplicated",
+			data:        "country,region,market,currency\nPL,EUROPE,CEE,PLN\nPL,EUROPE,CEE,EUR\n",
+			expectedErr: true,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			ref, err := Parse([]byte(test.data))
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("can't parse reference: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.expected, ref) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, ref)
+			}
+
+			if ref.Lookup("XX") != nil {
+				t.Errorf("expected no dimensions of an unknown country")
+			}
+			if g := ref.Lookup("PL"); g == nil || !reflect.DeepEqual(api.Geo{Region: "EUROPE", Market: "CEE", Currency: "PLN"}, *g) {
+				t.Errorf("expected and computed results differ: %v", g)
+			}
+		})
+	}
+}
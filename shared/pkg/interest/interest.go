@@ -0,0 +1,92 @@
+package interest
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"math"
+	"sort"
+	"time"
+)
+
+// Weights specifies how much a user tag of each action adds to the scores of its brand and category.
+var Weights = map[api.Action]float64{
+	api.VIEW: 1,
+	api.BUY:  5,
+}
+
+// decay returns the factor a score is multiplied by after d passes.
+func decay(d time.Duration, halfLife time.Duration) float64 {
+	return math.Exp2(-float64(d) / float64(halfLife))
+}
+
+// Add adds weight to the score of id as of time t, keeping at most limit of the highest scores.
+func Add(is *api.InterestScores, id string, weight float64, t time.Time, halfLife time.Duration, limit int) {
+	if is.Scores == nil {
+		is.Scores = make(map[string]float64)
+	}
+
+	if t.After(is.Time) {
+		if !is.Time.IsZero() {
+			f := decay(t.Sub(is.Time), halfLife)
+			for k := range is.Scores {
+				is.Scores[k] *= f
+			}
+		}
+		is.Time = t
+	}
+
+	// Late user tags have already decayed by the time they're added.
+	is.Scores[id] += weight * decay(is.Time.Sub(t), halfLife)
+
+	if len(is.Scores) > limit {
+		for _, r := range Rank(*is)[limit:] {
+			delete(is.Scores, r.ID)
+		}
+	}
+}
+
+// Update adds the user tag to the interests of its brand and category.
+func Update(i *api.Interests, ut *api.UserTag, halfLife time.Duration, limit int) {
+	w, ok := Weights[ut.Action]
+	if !ok {
+		return
+	}
+
+	Add(&i.Brands, ut.Product.BrandID, w, ut.Time, halfLife, limit)
+	Add(&i.Categories, ut.Product.CategoryID, w, ut.Time, halfLife, limit)
+}
+
+// Rank returns the scores in descending order. Ties are broken by ids.
+func Rank(is api.InterestScores) []api.InterestRow {
+	rows := make([]api.InterestRow, 0, len(is.Scores))
+	for id, score := range is.Scores {
+		rows = append(rows, api.InterestRow{ID: id, Score: score})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Score != rows[j].Score {
+			return rows[i].Score > rows[j].Score
+		}
+		return rows[i].ID < rows[j].ID
+	})
+
+	return rows
+}
+
+// DecayTo returns the scores decayed to time t. Scores aren't grown back for earlier times.
+func DecayTo(is api.InterestScores, t time.Time, halfLife time.Duration) api.InterestScores {
+	res := api.InterestScores{
+		Time:   is.Time,
+		Scores: make(map[string]float64, len(is.Scores)),
+	}
+
+	f := 1.0
+	if t.After(is.Time) {
+		f = decay(t.Sub(is.Time), halfLife)
+		res.Time = t
+	}
+	for k, v := range is.Scores {
+		res.Scores[k] = v * f
+	}
+
+	return res
+}
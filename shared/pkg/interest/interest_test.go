@@ -0,0 +1,95 @@
+package interest
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdate(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+	halfLife := 24 * time.Hour
+
+	tag := func(action api.Action, offset time.Duration, brand string, category string) api.UserTag {
+		return api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Product: api.Product{BrandID: brand, CategoryID: category},
+		}
+	}
+
+	ts := []struct {
+		name               string
+		tags               []api.UserTag
+		limit              int
+		expectedBrands     []api.InterestRow
+		expectedCategories []api.InterestRow
+	}{
+		{
+			name: "Buys are weighted above views",
+			tags: []api.UserTag{
+				tag(api.VIEW, 0, "Adidas", "SHOES"),
+				tag(api.VIEW, 0, "Adidas", "SHOES"),
+				tag(api.BUY, 0, "Nike", "SHOES"),
+			},
+			limit:              10,
+			expectedBrands:     []api.InterestRow{{ID: "Nike", Score: 5}, {ID: "Adidas", Score: 2}},
+			expectedCategories: []api.InterestRow{{ID: "SHOES", Score: 7}},
+		},
+		{
+			name: "Scores decay by half every half-life",
+			tags: []api.UserTag{
+				tag(api.BUY, 0, "Nike", "SHOES"),
+				tag(api.VIEW, 48*time.Hour, "Adidas", "HATS"),
+			},
+			limit:              10,
+			expectedBrands:     []api.InterestRow{{ID: "Nike", Score: 1.25}, {ID: "Adidas", Score: 1}},
+			expectedCategories: []api.InterestRow{{ID: "SHOES", Score: 1.25}, {ID: "HATS", Score: 1}},
+		},
+		{
+			name: "Late user tags are decayed before they're added",
+			tags: []api.UserTag{
+				tag(api.VIEW, 24*time.Hour, "Adidas", "HATS"),
+				tag(api.BUY, 0, "Nike", "SHOES"),
+			},
+			limit:              10,
+			expectedBrands:     []api.InterestRow{{ID: "Nike", Score: 2.5}, {ID: "Adidas", Score: 1}},
+			expectedCategories: []api.InterestRow{{ID: "SHOES", Score: 2.5}, {ID: "HATS", Score: 1}},
+		},
+		{
+			name: "Only the highest scores are kept",
+			tags: []api.UserTag{
+				tag(api.BUY, 0, "Nike", "SHOES"),
+				tag(api.VIEW, 0, "Adidas", "SHOES"),
+				tag(api.VIEW, 0, "Puma", "SHOES"),
+			},
+			limit:              2,
+			expectedBrands:     []api.InterestRow{{ID: "Nike", Score: 5}, {ID: "Adidas", Score: 1}},
+			expectedCategories: []api.InterestRow{{ID: "SHOES", Score: 7}},
+		},
+	}
+
+	round := func(rows []api.InterestRow) []api.InterestRow {
+		for i := range rows {
+			rows[i].Score = math.Round(rows[i].Score*1e9) / 1e9
+		}
+		return rows
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			var is api.Interests
+			for j := range test.tags {
+				Update(&is, &test.tags[j], halfLife, test.limit)
+			}
+
+			brands, categories := round(Rank(is.Brands)), round(Rank(is.Categories))
+			if !reflect.DeepEqual(test.expectedBrands, brands) || !reflect.DeepEqual(test.expectedCategories, categories) {
+				t.Errorf("expected and computed results differ: %v %v, %v %v", test.expectedBrands, test.expectedCategories, brands, categories)
+			}
+		})
+	}
+}
@@ -6,6 +6,54 @@ const (
 	Bootstrap        string      = "kafka-cluster-kafka-bootstrap.kafka.svc.cluster.local:9092"
 	UserProfileTopic goka.Stream = "user-profile"
 	AggregateTopic   goka.Stream = "aggregate"
+	ForwarderGroup   goka.Group  = "forwarder"
 	SinkGroup        goka.Group  = "collector"
 	SinkTable        goka.Table  = "collector-table"
+	WatermarkTopic   goka.Stream = "watermark"
+	WatermarkGroup   goka.Group  = "watermark"
+	WatermarkTable   goka.Table  = "watermark-table"
+	FunnelGroup      goka.Group  = "funnel"
+	FunnelTopic      goka.Stream = "funnel"
+	FunnelSinkGroup  goka.Group  = "funnel-collector"
+	FunnelSinkTable  goka.Table  = "funnel-collector-table"
+	SessionGroup     goka.Group  = "session"
+	SessionTopic     goka.Stream = "session"
+	SessionSinkGroup goka.Group  = "session-collector"
+	SessionSinkTable goka.Table  = "session-collector-table"
+
+	CooccurrenceGroup     goka.Group  = "cooccurrence"
+	CooccurrenceTopic     goka.Stream = "cooccurrence"
+	CooccurrenceSinkGroup goka.Group  = "cooccurrence-collector"
+	CooccurrenceSinkTable goka.Table  = "cooccurrence-collector-table"
+
+	AlertGroup       goka.Group  = "alert"
+	AlertTopic       goka.Stream = "alert"
+	AlertNotifyGroup goka.Group  = "alert-notifier"
+
+	AnomalyGroup     goka.Group  = "anomaly"
+	AnomalyTopic     goka.Stream = "anomaly"
+	AnomalySinkGroup goka.Group  = "anomaly-collector"
+	AnomalySinkTable goka.Table  = "anomaly-collector-table"
+
+	AttributionGroup     goka.Group  = "attribution"
+	AttributionTopic     goka.Stream = "attribution"
+	AttributionSinkGroup goka.Group  = "attribution-collector"
+	AttributionSinkTable goka.Table  = "attribution-collector-table"
+
+	CohortGroup     goka.Group  = "cohort"
+	CohortTopic     goka.Stream = "cohort"
+	CohortSinkGroup goka.Group  = "cohort-collector"
+	CohortSinkTable goka.Table  = "cohort-collector-table"
+
+	PathGroup     goka.Group  = "path"
+	PathTopic     goka.Stream = "path"
+	PathSinkGroup goka.Group  = "path-collector"
+	PathSinkTable goka.Table  = "path-collector-table"
+
+	// CatalogTable is a compacted topic of the product catalog keyed by product ids.
+	CatalogTable goka.Table = "product-catalog"
+
+	SegmentTopic     goka.Stream = "segment"
+	SegmentSinkGroup goka.Group  = "segment-collector"
+	SegmentSinkTable goka.Table  = "segment-collector-table"
 )
@@ -0,0 +1,98 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/klog/v2"
+	"os"
+	"sync"
+	"time"
+)
+
+// File holds the parsed contents of a local file and reloads them when the file is modified.
+type File[T any] struct {
+	path  string
+	parse func(data []byte) (T, error)
+
+	mu      sync.RWMutex
+	value   T
+	modTime time.Time
+}
+
+// NewFile loads the file. It fails if the file can't be read or parsed.
+func NewFile[T any](path string, parse func(data []byte) (T, error)) (*File[T], error) {
+	f := &File[T]{
+		path:  path,
+		parse: parse,
+	}
+
+	_, err := f.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Get returns the most recently loaded contents.
+func (f *File[T]) Get() T {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.value
+}
+
+// Reload loads the file if it's been modified since it was last loaded and reports whether it was.
+// The previous contents are kept if the file can't be read or parsed.
+func (f *File[T]) Reload() (bool, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("can't stat %s: %w", f.path, err)
+	}
+
+	f.mu.RLock()
+	modTime := f.modTime
+	f.mu.RUnlock()
+	if fi.ModTime().Equal(modTime) {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("can't read %s: %w", f.path, err)
+	}
+
+	v, err := f.parse(data)
+	if err != nil {
+		return false, fmt.Errorf("can't parse %s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	f.value = v
+	f.modTime = fi.ModTime()
+	f.mu.Unlock()
+
+	return true, nil
+}
+
+// Run checks the file for modifications every interval until ctx is done.
+func (f *File[T]) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := f.Reload()
+			if err != nil {
+				klog.Errorf("can't reload file, keeping the previous contents: %v", err)
+				continue
+			}
+			if reloaded {
+				klog.Infof("Reloaded %s", f.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package reload
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileReload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "value")
+	write := func(data string, modTime time.Time) {
+		err := os.WriteFile(path, []byte(data), 0o644)
+		if err != nil {
+			t.Fatalf("can't write file: %v", err)
+		}
+		err = os.Chtimes(path, modTime, modTime)
+		if err != nil {
+			t.Fatalf("can't change file times: %v", err)
+		}
+	}
+	parse := func(data []byte) (int, error) {
+		return strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+	write("1", base)
+
+	f, err := NewFile(path, parse)
+	if err != nil {
+		t.Fatalf("can't load file: %v", err)
+	}
+	if f.Get() != 1 {
+		t.Errorf("expected 1, got %d", f.Get())
+	}
+
+	reloaded, err := f.Reload()
+	if err != nil || reloaded {
+		t.Errorf("expected unmodified file not to be reloaded: %v, %v", reloaded, err)
+	}
+
+	write("2", base.Add(time.Second))
+	reloaded, err = f.Reload()
+	if err != nil || !reloaded || f.Get() != 2 {
+		t.Errorf("expected modified file to be reloaded: %v, %v, %d", reloaded, err, f.Get())
+	}
+
+	write("invalid", base.Add(2*time.Second))
+	_, err = f.Reload()
+	if err == nil {
+		t.Errorf("expected an error reloading an invalid file")
+	}
+	if f.Get() != 2 {
+		t.Errorf("expected the previous contents to be kept, got %d", f.Get())
+	}
+}
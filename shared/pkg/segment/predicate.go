@@ -0,0 +1,351 @@
+package segment
+
+import (
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Predicate reports whether a user tag matches a rule.
+type Predicate func(ut *api.UserTag) bool
+
+// field is a property of user tags rules can compare.
+type field struct {
+	extract func(ut *api.UserTag) string
+	// numeric fields are compared as numbers and support ordering operators.
+	numeric  bool
+	validate func(s string) error
+}
+
+var fields = map[string]field{
+	"action": {
+		extract: func(ut *api.UserTag) string { return ut.Action.String() },
+		validate: func(s string) error {
+			_, err := api.ParseAction(s)
+			return err
+		},
+	},
+	"price": {
+		extract: func(ut *api.UserTag) string { return strconv.FormatInt(int64(ut.Product.Price), 10) },
+		numeric: true,
+	},
+	string(api.ProductDimension.Column): {
+		extract: api.ProductDimension.Extract,
+		numeric: true,
+	},
+}
+
+func init() {
+	// Rules are evaluated against the profiles, which aren't enriched.
+	for _, d := range api.Dimensions {
+		if d.Derived {
+			continue
+		}
+		fields[string(d.Column)] = field{extract: d.Extract, validate: d.Validate}
+	}
+}
+
+// Parse compiles a rule into a predicate over user tags.
+// Rules compare fields of user tags with values and combine the comparisons with 'and', 'or', 'not' and parentheses, e.g.
+//
+//	action = VIEW and category_id = WOMEN_SHOES and device = MOBILE
+//	brand_id in (Nike, "New Balance") and not (country = PL or price >= 10000)
+//
+// Values containing spaces or operators have to be quoted. Ordering operators only apply to numeric fields.
+func Parse(rule string) (Predicate, error) {
+	tokens, err := tokenize(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	pred, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+
+	return pred, nil
+}
+
+type tokenKind int
+
+const (
+	word tokenKind = iota + 1
+	quoted
+	punct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+func tokenize(s string) ([]token, error) {
+	tokens := make([]token, 0)
+
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case isWordRune(r):
+			j := i
+			for j < len(rs) && isWordRune(rs[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: word, text: string(rs[i:j])})
+			i = j
+
+		case r == '"':
+			j := i + 1
+			for j < len(rs) && rs[j] != '"' {
+				if rs[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			text, err := strconv.Unquote(string(rs[i : j+1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d: %w", i, err)
+			}
+			tokens = append(tokens, token{kind: quoted, text: text})
+			i = j + 1
+
+		case r == '!' || r == '<' || r == '>':
+			if i+1 < len(rs) && rs[i+1] == '=' {
+				tokens = append(tokens, token{kind: punct, text: string(rs[i : i+2])})
+				i += 2
+				continue
+			}
+			if r == '!' {
+				return nil, fmt.Errorf("unexpected '!' at offset %d", i)
+			}
+			tokens = append(tokens, token{kind: punct, text: string(r)})
+			i++
+
+		case r == '=' || r == '(' || r == ')' || r == ',':
+			tokens = append(tokens, token{kind: punct, text: string(r)})
+			i++
+
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", r, i)
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+// keyword consumes the next token if it's the given case-insensitive keyword.
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == word && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// punct consumes the next token if it's the given punctuation.
+func (p *parser) punct(s string) bool {
+	t := p.peek()
+	if t.kind == punct && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (Predicate, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = func(l, r Predicate) Predicate {
+			return func(ut *api.UserTag) bool { return l(ut) || r(ut) }
+		}(l, r)
+	}
+
+	return l, nil
+}
+
+func (p *parser) and() (Predicate, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = func(l, r Predicate) Predicate {
+			return func(ut *api.UserTag) bool { return l(ut) && r(ut) }
+		}(l, r)
+	}
+
+	return l, nil
+}
+
+func (p *parser) unary() (Predicate, error) {
+	if p.keyword("not") {
+		pred, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(ut *api.UserTag) bool { return !pred(ut) }, nil
+	}
+
+	if p.punct("(") {
+		pred, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.punct(")") {
+			return nil, fmt.Errorf("expected ')' but got %q", p.peek().text)
+		}
+		return pred, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) value(f field) (string, error) {
+	t := p.peek()
+	if t.kind != word && t.kind != quoted {
+		return "", fmt.Errorf("expected a value but got %q", t.text)
+	}
+	p.pos++
+
+	if f.numeric {
+		_, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a valid number", t.text)
+		}
+	}
+	if f.validate != nil {
+		err := f.validate(t.text)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return t.text, nil
+}
+
+func (p *parser) comparison() (Predicate, error) {
+	t := p.peek()
+	if t.kind != word {
+		return nil, fmt.Errorf("expected a field but got %q", t.text)
+	}
+	name := strings.ToLower(t.text)
+	f, ok := fields[name]
+	if !ok {
+		return nil, fmt.Errorf("%q is not a valid field", t.text)
+	}
+	p.pos++
+
+	if p.keyword("in") {
+		if !p.punct("(") {
+			return nil, fmt.Errorf("expected '(' but got %q", p.peek().text)
+		}
+		set := make(map[string]bool)
+		for {
+			v, err := p.value(f)
+			if err != nil {
+				return nil, err
+			}
+			set[canonical(f, v)] = true
+
+			if p.punct(")") {
+				break
+			}
+			if !p.punct(",") {
+				return nil, fmt.Errorf("expected ',' or ')' but got %q", p.peek().text)
+			}
+		}
+		return func(ut *api.UserTag) bool { return set[f.extract(ut)] }, nil
+	}
+
+	op := p.peek()
+	if op.kind != punct {
+		return nil, fmt.Errorf("expected an operator but got %q", op.text)
+	}
+	p.pos++
+
+	v, err := p.value(f)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.text {
+	case "=":
+		v = canonical(f, v)
+		return func(ut *api.UserTag) bool { return f.extract(ut) == v }, nil
+	case "!=":
+		v = canonical(f, v)
+		return func(ut *api.UserTag) bool { return f.extract(ut) != v }, nil
+	case "<", "<=", ">", ">=":
+		if !f.numeric {
+			return nil, fmt.Errorf("operator %q doesn't apply to field %q", op.text, name)
+		}
+		n, _ := strconv.ParseInt(v, 10, 64)
+		cmp := map[string]func(int64) bool{
+			"<":  func(x int64) bool { return x < n },
+			"<=": func(x int64) bool { return x <= n },
+			">":  func(x int64) bool { return x > n },
+			">=": func(x int64) bool { return x >= n },
+		}[op.text]
+		return func(ut *api.UserTag) bool {
+			x, err := strconv.ParseInt(f.extract(ut), 10, 64)
+			return err == nil && cmp(x)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%q is not a valid operator", op.text)
+	}
+}
+
+// canonical returns the representation of a value the field extracts, so that e.g. numbers with leading zeros compare equal.
+func canonical(f field, v string) string {
+	if f.numeric {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return strconv.FormatInt(n, 10)
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+package segment
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	ut := api.UserTag{
+		Country: "PL",
+		Device:  api.MOBILE,
+		Action:  api.VIEW,
+		Origin:  "X",
+		Product: api.Product{ProductID: 42, BrandID: "New Balance", CategoryID: "WOMEN_SHOES", Price: 1500},
+	}
+
+	ts := []struct {
+		name     string
+		rule     string
+		expected bool
+	}{
+		{
+			name:     "Equality matches",
+			rule:     "action = VIEW and category_id = WOMEN_SHOES and device = MOBILE",
+			expected: true,
+		},
+		{
+			name:     "Equality doesn't match other values",
+			rule:     "action = BUY",
+			expected: false,
+		},
+		{
+			name:     "Quoted values can contain spaces",
+			rule:     `brand_id = "New Balance"`,
+			expected: true,
+		},
+		{
+			name:     "Keywords and fields are case-insensitive",
+			rule:     "NOT Country != PL AND device = MOBILE",
+			expected: true,
+		},
+		{
+			name:     "Or matches if either side matches",
+			rule:     "origin = Y or origin = X",
+			expected: true,
+		},
+		{
+			name:     "And binds tighter than or",
+			rule:     "origin = X or origin = Y and action = BUY",
+			expected: true,
+		},
+		{
+			name:     "Parentheses override precedence",
+			rule:     "(origin = X or origin = Y) and action = BUY",
+			expected: false,
+		},
+		{
+			name:     "In matches any of the values",
+			rule:     `brand_id in (Nike, "New Balance")`,
+			expected: true,
+		},
+		{
+			name:     "Numeric fields are ordered",
+			rule:     "price >= 1500 and price < 2000 and product_id > 41",
+			expected: true,
+		},
+		{
+			name:     "Numeric fields are compared as numbers",
+			rule:     "product_id = 042",
+			expected: true,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			pred, err := Parse(test.rule)
+			if err != nil {
+				t.Fatalf("can't parse rule: %v", err)
+			}
+
+			computed := pred(&ut)
+			if !reflect.DeepEqual(test.expected, computed) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, computed)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	ts := []struct {
+		name string
+		rule string
+	}{
+		{
+			name: "Empty rule",
+			rule: "",
+		},
+		{
+			name: "Unknown field",
+			rule: "colour = red",
+		},
+		{
+			name: "Invalid action",
+			rule: "action = CLICK",
+		},
+		{
+			name: "Invalid device",
+			rule: "device = WATCH",
+		},
+		{
+			name: "Ordering a string field",
+			rule: "brand_id > Nike",
+		},
+		{
+			name: "Non-numeric value of a numeric field",
+			rule: "price = cheap",
+		},
+		{
+			name: "Unbalanced parentheses",
+			rule: "(action = BUY",
+		},
+		{
+			name: "Trailing tokens",
+			rule: "action = BUY origin = X",
+		},
+		{
+			name: "Unterminated string",
+			rule: `brand_id = "Nike`,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.rule)
+			if err == nil {
+				t.Errorf("expected an error parsing %q", test.rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package segment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Segment is an audience of the cookies with at least MinCount user tags matching Rule within the lookback window.
+type Segment struct {
+	ID   string `json:"id"`
+	Rule string `json:"rule"`
+	// Within is the lookback window before the cookie's most recent user tag, e.g. "7d" or "12h". Empty means no limit.
+	Within   string `json:"within,omitempty"`
+	MinCount int    `json:"min_count,omitempty"`
+
+	match  Predicate
+	within time.Duration
+}
+
+type segmentsFile struct {
+	Segments []Segment `json:"segments"`
+}
+
+// Load reads and validates the segment definitions file.
+func Load(path string) ([]Segment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read segments file: %w", err)
+	}
+
+	var sf segmentsFile
+	err = json.Unmarshal(data, &sf)
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal segments file: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	for i := range sf.Segments {
+		err = sf.Segments[i].Init()
+		if err != nil {
+			return nil, fmt.Errorf("invalid segment %q: %w", sf.Segments[i].ID, err)
+		}
+
+		if ids[sf.Segments[i].ID] {
+			return nil, fmt.Errorf("duplicate segment %q", sf.Segments[i].ID)
+		}
+		ids[sf.Segments[i].ID] = true
+	}
+
+	return sf.Segments, nil
+}
+
+// Init validates the segment and compiles its rule.
+func (s *Segment) Init() error {
+	var err error
+
+	if len(s.ID) == 0 {
+		return errors.New("id is missing")
+	}
+	// Member keys are prefixed with segment ids followed by a slash.
+	if strings.Contains(s.ID, "/") {
+		return errors.New("id can't contain '/'")
+	}
+
+	s.match, err = Parse(s.Rule)
+	if err != nil {
+		return fmt.Errorf("can't parse rule: %w", err)
+	}
+
+	if len(s.Within) > 0 {
+		s.within, err = parseWithin(s.Within)
+		if err != nil {
+			return fmt.Errorf("can't parse within: %w", err)
+		}
+	}
+
+	if s.MinCount < 0 {
+		return errors.New("min_count can't be negative")
+	}
+	if s.MinCount == 0 {
+		s.MinCount = 1
+	}
+
+	return nil
+}
+
+// parseWithin extends time.ParseDuration with whole days, e.g. "7d".
+func parseWithin(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+
+	if strings.HasSuffix(s, "d") {
+		var days int
+		days, err = strconv.Atoi(strings.TrimSuffix(s, "d"))
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid duration", s)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%q is not positive", s)
+	}
+
+	return d, nil
+}
+
+// Until reports whether the profile belongs to the segment as of now and when it leaves the segment unless more of its
+// user tags match. Memberships of segments without a lookback window never end, in which case the time is zero.
+func (s *Segment) Until(up *api.UserProfile, now time.Time) (time.Time, bool) {
+	matched := make([]time.Time, 0)
+	for _, a := range api.ProfileActions() {
+		uts := up.Tags(a)
+		for i := range uts {
+			// User tags are sorted in descending time order.
+			if s.within > 0 && !uts[i].Time.After(now.Add(-s.within)) {
+				break
+			}
+			if s.match(&uts[i]) {
+				matched = append(matched, uts[i].Time)
+			}
+		}
+	}
+
+	if len(matched) < s.MinCount {
+		return time.Time{}, false
+	}
+	if s.within == 0 {
+		return time.Time{}, true
+	}
+
+	// The membership lasts until the MinCount-th most recent matching user tag falls out of the window.
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].After(matched[j])
+	})
+
+	return matched[s.MinCount-1].Add(s.within), true
+}
+
+// Contains reports whether the profile belongs to the segment as of now.
+func (s *Segment) Contains(up *api.UserProfile, now time.Time) bool {
+	_, ok := s.Until(up, now)
+	return ok
+}
+
+// Memberships returns the segments the profile belongs to as of now along with when it leaves each of them.
+func Memberships(segments []Segment, up *api.UserProfile, now time.Time) map[string]time.Time {
+	ms := make(map[string]time.Time)
+	for i := range segments {
+		if until, ok := segments[i].Until(up, now); ok {
+			ms[segments[i].ID] = until
+		}
+	}
+
+	return ms
+}
+
+// Evaluate returns the sorted ids of the segments the profile belongs to as of now.
+// Windows end at now rather than at the profile's most recent user tag, so that idle cookies leave the segments.
+func Evaluate(segments []Segment, up *api.UserProfile, now time.Time) []string {
+	return IDs(Memberships(segments, up, now))
+}
+
+// IDs returns the sorted ids of the memberships.
+func IDs(ms map[string]time.Time) []string {
+	ids := make([]string, 0, len(ms))
+	for id := range ms {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Extended returns the sorted ids of the segments the profile belongs to as of both prev and curr, but leaves at
+// another time as of curr.
+func Extended(prev, curr map[string]time.Time) []string {
+	ids := make([]string, 0)
+	for id, until := range curr {
+		if p, ok := prev[id]; ok && !p.Equal(until) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Diff returns the segments present in curr but not in prev and the other way around. Both have to be sorted.
+func Diff(prev, curr []string) (entered []string, exited []string) {
+	entered, exited = make([]string, 0), make([]string, 0)
+
+	i, j := 0, 0
+	for i < len(prev) || j < len(curr) {
+		switch {
+		case j >= len(curr) || (i < len(prev) && prev[i] < curr[j]):
+			exited = append(exited, prev[i])
+			i++
+		case i >= len(prev) || curr[j] < prev[i]:
+			entered = append(entered, curr[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+
+	return entered, exited
+}
@@ -0,0 +1,202 @@
+package segment
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	tag := func(action api.Action, offset time.Duration, brand string) api.UserTag {
+		return api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Device:  api.MOBILE,
+			Product: api.Product{BrandID: brand, CategoryID: "WOMEN_SHOES"},
+		}
+	}
+
+	segments := []Segment{
+		{ID: "nike-buyers-7d", Rule: "action = BUY and brand_id = Nike", Within: "7d"},
+		{ID: "frequent-viewers", Rule: "action = VIEW and device = MOBILE", MinCount: 3},
+	}
+	for i := range segments {
+		err := segments[i].Init()
+		if err != nil {
+			t.Fatalf("can't init segment: %v", err)
+		}
+	}
+
+	ts := []struct {
+		name     string
+		profile  api.UserProfile
+		now      time.Time
+		expected []string
+	}{
+		{
+			name:     "Empty profile belongs to no segments",
+			profile:  api.UserProfile{},
+			now:      base,
+			expected: []string{},
+		},
+		{
+			name: "Recent buy and enough views",
+			profile: api.UserProfile{
+				Views: []api.UserTag{
+					tag(api.VIEW, 3*time.Hour, "Adidas"),
+					tag(api.VIEW, 2*time.Hour, "Adidas"),
+					tag(api.VIEW, time.Hour, "Adidas"),
+				},
+				Buys: []api.UserTag{
+					tag(api.BUY, 0, "Nike"),
+				},
+			},
+			now:      base.Add(3 * time.Hour),
+			expected: []string{"frequent-viewers", "nike-buyers-7d"},
+		},
+		{
+			name: "Idle cookie leaves the segments its user tags fell out of the window of",
+			profile: api.UserProfile{
+				Views: []api.UserTag{
+					tag(api.VIEW, 3*time.Hour, "Adidas"),
+					tag(api.VIEW, 2*time.Hour, "Adidas"),
+					tag(api.VIEW, time.Hour, "Adidas"),
+				},
+				Buys: []api.UserTag{
+					tag(api.BUY, 0, "Nike"),
+				},
+			},
+			now:      base.Add(8 * 24 * time.Hour),
+			expected: []string{"frequent-viewers"},
+		},
+		{
+			name: "Buy outside of the window",
+			profile: api.UserProfile{
+				Views: []api.UserTag{
+					tag(api.VIEW, 8*24*time.Hour, "Adidas"),
+				},
+				Buys: []api.UserTag{
+					tag(api.BUY, 0, "Nike"),
+				},
+			},
+			now:      base.Add(8 * 24 * time.Hour),
+			expected: []string{},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			computed := Evaluate(segments, &test.profile, test.now)
+			if !reflect.DeepEqual(test.expected, computed) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, computed)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ts := []struct {
+		name            string
+		prev            []string
+		curr            []string
+		expectedEntered []string
+		expectedExited  []string
+	}{
+		{
+			name:            "No segments",
+			prev:            nil,
+			curr:            []string{},
+			expectedEntered: []string{},
+			expectedExited:  []string{},
+		},
+		{
+			name:            "Entered and exited segments",
+			prev:            []string{"a", "c", "d"},
+			curr:            []string{"b", "c", "e"},
+			expectedEntered: []string{"b", "e"},
+			expectedExited:  []string{"a", "d"},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			entered, exited := Diff(test.prev, test.curr)
+			if !reflect.DeepEqual(test.expectedEntered, entered) || !reflect.DeepEqual(test.expectedExited, exited) {
+				t.Errorf("expected and computed results differ: %v %v, %v %v", test.expectedEntered, test.expectedExited, entered, exited)
+			}
+		})
+	}
+}
+
+func TestMemberships(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	tag := func(action api.Action, offset time.Duration, brand string) api.UserTag {
+		return api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Device:  api.MOBILE,
+			Product: api.Product{BrandID: brand, CategoryID: "WOMEN_SHOES"},
+		}
+	}
+
+	segments := []Segment{
+		{ID: "nike-buyers-7d", Rule: "action = BUY and brand_id = Nike", Within: "7d"},
+		{ID: "repeat-viewers-1d", Rule: "action = VIEW", Within: "1d", MinCount: 2},
+		{ID: "frequent-viewers", Rule: "action = VIEW and device = MOBILE", MinCount: 3},
+	}
+	for i := range segments {
+		err := segments[i].Init()
+		if err != nil {
+			t.Fatalf("can't init segment: %v", err)
+		}
+	}
+
+	ts := []struct {
+		name     string
+		profile  api.UserProfile
+		now      time.Time
+		expected map[string]time.Time
+	}{
+		{
+			name:     "Empty profile belongs to no segments",
+			profile:  api.UserProfile{},
+			now:      base,
+			expected: map[string]time.Time{},
+		},
+		{
+			name: "Memberships end when the user tags needed fall out of the window",
+			profile: api.UserProfile{
+				Views: []api.UserTag{
+					tag(api.VIEW, 3*time.Hour, "Adidas"),
+					tag(api.VIEW, 2*time.Hour, "Adidas"),
+					tag(api.VIEW, time.Hour, "Adidas"),
+				},
+				Buys: []api.UserTag{
+					tag(api.BUY, 0, "Nike"),
+				},
+			},
+			now: base.Add(3 * time.Hour),
+			expected: map[string]time.Time{
+				"nike-buyers-7d":    base.Add(7 * 24 * time.Hour),
+				"repeat-viewers-1d": base.Add(26 * time.Hour),
+				"frequent-viewers":  {},
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			computed := Memberships(segments, &test.profile, test.now)
+			if !reflect.DeepEqual(test.expected, computed) {
+				t.Errorf("expected and computed results differ: %v, %v", test.expected, computed)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"time"
+)
+
+// Continues reports whether ut belongs to session s, i.e. it happened on the same device
+// and isn't separated from the session's boundaries by more than gap.
+func Continues(s api.Session, ut *api.UserTag, gap time.Duration) bool {
+	if s.Start.IsZero() || s.Device != ut.Device {
+		return false
+	}
+
+	return !ut.Time.Before(s.Start.Add(-gap)) && !ut.Time.After(s.End.Add(gap))
+}
+
+// Add extends session s with ut.
+func Add(s *api.Session, ut *api.UserTag) {
+	if s.Start.IsZero() || ut.Time.Before(s.Start) {
+		s.Start = ut.Time
+	}
+	if ut.Time.After(s.End) {
+		s.End = ut.Time
+	}
+	s.Device = ut.Device
+
+	switch ut.Action {
+	case api.VIEW:
+		s.Views += 1
+	case api.BUY:
+		s.Buys += 1
+		s.Revenue += ut.Product.NormalizedPrice()
+	}
+}
+
+// Remove retracts the BUY refunded by ut from session s if it was part of it. The session keeps its boundaries.
+func Remove(s *api.Session, ut *api.UserTag) bool {
+	p := ut.Purchase()
+	if s.Start.IsZero() || s.Device != p.Device || p.Time.Before(s.Start) || p.Time.After(s.End) || s.Buys == 0 {
+		return false
+	}
+
+	s.Buys -= 1
+	s.Revenue -= p.Product.NormalizedPrice()
+	return true
+}
+
+// Sessionize groups the user tags of views and buys, both sorted in descending time order, into sessions.
+// Sessions are returned in descending time order as well.
+func Sessionize(views []api.UserTag, buys []api.UserTag, gap time.Duration) []api.Session {
+	res := make([]api.Session, 0)
+
+	var curr api.Session
+	for i, j := 0, 0; i < len(views) || j < len(buys); {
+		var ut *api.UserTag
+		if j >= len(buys) || (i < len(views) && views[i].Time.After(buys[j].Time)) {
+			ut = &views[i]
+			i++
+		} else {
+			ut = &buys[j]
+			j++
+		}
+
+		if !Continues(curr, ut, gap) {
+			if !curr.Start.IsZero() {
+				res = append(res, curr)
+			}
+			curr = api.Session{}
+		}
+		Add(&curr, ut)
+	}
+
+	if !curr.Start.IsZero() {
+		res = append(res, curr)
+	}
+
+	return res
+}
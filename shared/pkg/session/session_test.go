@@ -0,0 +1,136 @@
+package session
+
+import (
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionize(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	tag := func(action api.Action, offset time.Duration, device api.Device, price int32) api.UserTag {
+		return api.UserTag{
+			Time:    base.Add(offset),
+			Action:  action,
+			Device:  device,
+			Product: api.Product{Price: price},
+		}
+	}
+
+	ts := []struct {
+		name     string
+		views    []api.UserTag
+		buys     []api.UserTag
+		gap      time.Duration
+		expected []api.Session
+	}{
+		{
+			name:     "No user tags produce no sessions",
+			views:    []api.UserTag{},
+			buys:     []api.UserTag{},
+			gap:      30 * time.Minute,
+			expected: []api.Session{},
+		},
+		{
+			name: "Views and buys within the gap form a single session",
+			views: []api.UserTag{
+				tag(api.VIEW, 20*time.Minute, api.PC, 100),
+				tag(api.VIEW, 0, api.PC, 100),
+			},
+			buys: []api.UserTag{
+				tag(api.BUY, 25*time.Minute, api.PC, 100),
+			},
+			gap: 30 * time.Minute,
+			expected: []api.Session{
+				{Start: base, End: base.Add(25 * time.Minute), Device: api.PC, Views: 2, Buys: 1, Revenue: 100},
+			},
+		},
+		{
+			name: "Inactivity longer than the gap starts a new session",
+			views: []api.UserTag{
+				tag(api.VIEW, 2*time.Hour, api.PC, 100),
+				tag(api.VIEW, 0, api.PC, 100),
+			},
+			buys: []api.UserTag{},
+			gap:  30 * time.Minute,
+			expected: []api.Session{
+				{Start: base.Add(2 * time.Hour), End: base.Add(2 * time.Hour), Device: api.PC, Views: 1},
+				{Start: base, End: base, Device: api.PC, Views: 1},
+			},
+		},
+		{
+			name: "Changing the device starts a new session",
+			views: []api.UserTag{
+				tag(api.VIEW, time.Minute, api.MOBILE, 100),
+				tag(api.VIEW, 0, api.PC, 100),
+			},
+			buys: []api.UserTag{},
+			gap:  30 * time.Minute,
+			expected: []api.Session{
+				{Start: base.Add(time.Minute), End: base.Add(time.Minute), Device: api.MOBILE, Views: 1},
+				{Start: base, End: base, Device: api.PC, Views: 1},
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res := Sessionize(test.views, test.buys, test.gap)
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %v != %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+	s := api.Session{Start: base, End: base.Add(10 * time.Minute), Device: api.PC, Views: 2, Buys: 1, Revenue: 100}
+
+	refund := func(purchaseOffset time.Duration, device api.Device) api.UserTag {
+		purchaseTime := base.Add(purchaseOffset)
+		return api.UserTag{
+			Time:         base.Add(time.Hour),
+			Action:       api.REFUND,
+			Device:       device,
+			Product:      api.Product{Price: 100},
+			PurchaseTime: &purchaseTime,
+		}
+	}
+
+	ts := []struct {
+		name     string
+		refund   api.UserTag
+		expected api.Session
+	}{
+		{
+			name:     "BUY within the session is retracted",
+			refund:   refund(5*time.Minute, api.PC),
+			expected: api.Session{Start: base, End: base.Add(10 * time.Minute), Device: api.PC, Views: 2},
+		},
+		{
+			name:     "BUY of an earlier session isn't retracted",
+			refund:   refund(-time.Hour, api.PC),
+			expected: s,
+		},
+		{
+			name:     "BUY on another device isn't retracted",
+			refund:   refund(5*time.Minute, api.MOBILE),
+			expected: s,
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res := s
+			Remove(&res, &test.refund)
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %v != %v", test.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package topk
+
+import "time"
+
+type Hour struct {
+	Hour   time.Time `json:"hour"`
+	Sketch Sketch    `json:"sketch"`
+}
+
+// Hourly counts items in hourly sketches covering a sliding window.
+type Hourly struct {
+	Hours []Hour `json:"hours"`
+}
+
+// Add counts item at t and evicts the hours which fall out of window.
+func (h *Hourly) Add(item string, t time.Time, capacity int, window time.Duration) {
+	hour := t.Truncate(time.Hour)
+
+	var latest time.Time
+	found := false
+	for i := range h.Hours {
+		if h.Hours[i].Hour.Equal(hour) {
+			h.Hours[i].Sketch.Add(item, 1)
+			found = true
+		}
+		if h.Hours[i].Hour.After(latest) {
+			latest = h.Hours[i].Hour
+		}
+	}
+
+	if !found {
+		s := NewSketch(capacity)
+		s.Add(item, 1)
+		h.Hours = append(h.Hours, Hour{Hour: hour, Sketch: *s})
+		if hour.After(latest) {
+			latest = hour
+		}
+	}
+
+	hours := make([]Hour, 0, len(h.Hours))
+	for _, x := range h.Hours {
+		if x.Hour.After(latest.Add(-window)) {
+			hours = append(hours, x)
+		}
+	}
+	h.Hours = hours
+}
+
+// Sketch merges the hourly sketches.
+func (h *Hourly) Sketch() *Sketch {
+	capacity := 0
+	for _, x := range h.Hours {
+		if x.Sketch.Capacity > capacity {
+			capacity = x.Sketch.Capacity
+		}
+	}
+
+	res := NewSketch(capacity)
+	for _, x := range h.Hours {
+		res.Merge(x.Sketch)
+	}
+
+	return res
+}
@@ -0,0 +1,32 @@
+package topk
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHourlyAdd(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+
+	h := Hourly{}
+	h.Add("1", base.Add(10*time.Minute), 10, 24*time.Hour)
+	h.Add("2", base.Add(20*time.Minute), 10, 24*time.Hour)
+	h.Add("1", base.Add(time.Hour), 10, 24*time.Hour)
+	if len(h.Hours) != 2 {
+		t.Fatalf("expected 2 hours, got %d", len(h.Hours))
+	}
+
+	expected := []Counter{{Item: "1", Count: 2}, {Item: "2", Count: 1}}
+	res := h.Sketch().Top(10)
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected and computed results differ: %v != %v", expected, res)
+	}
+
+	h.Add("2", base.Add(24*time.Hour), 10, 24*time.Hour)
+	expected = []Counter{{Item: "1", Count: 1}, {Item: "2", Count: 1}}
+	res = h.Sketch().Top(10)
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected and computed results differ after eviction: %v != %v", expected, res)
+	}
+}
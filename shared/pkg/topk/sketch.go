@@ -0,0 +1,128 @@
+package topk
+
+import (
+	"sort"
+)
+
+// Counter is an item monitored by a Sketch.
+// Count overestimates the item's true weight by at most Error.
+type Counter struct {
+	Item  string `json:"i"`
+	Count int64  `json:"c"`
+	Error int64  `json:"e,omitempty"`
+}
+
+// Sketch tracks the heaviest items of a stream in bounded space using the Space-Saving algorithm.
+// Weights can't be subtracted soundly, since an evicted item's weight is carried over by the error bounds of the others.
+type Sketch struct {
+	Capacity int       `json:"capacity"`
+	Counters []Counter `json:"counters"`
+}
+
+func NewSketch(capacity int) *Sketch {
+	return &Sketch{
+		Capacity: capacity,
+		Counters: make([]Counter, 0, capacity),
+	}
+}
+
+// Add increases the weight of item by w.
+// When the sketch is full, the lightest item is evicted and item inherits its weight as the error bound.
+func (s *Sketch) Add(item string, w int64) {
+	min := -1
+	for i := range s.Counters {
+		if s.Counters[i].Item == item {
+			s.Counters[i].Count += w
+			return
+		}
+
+		if min < 0 || s.Counters[i].Count < s.Counters[min].Count {
+			min = i
+		}
+	}
+
+	if len(s.Counters) < s.Capacity {
+		s.Counters = append(s.Counters, Counter{Item: item, Count: w})
+		return
+	}
+
+	if min < 0 {
+		return
+	}
+
+	s.Counters[min] = Counter{
+		Item:  item,
+		Count: s.Counters[min].Count + w,
+		Error: s.Counters[min].Count,
+	}
+}
+
+// Merge adds the counters of o to the sketch, keeping the heaviest ones within the capacity.
+// Items missing from either sketch are counted with its minimum count, the most they can weigh in it.
+func (s *Sketch) Merge(o Sketch) {
+	sMin, oMin := s.minCount(), o.minCount()
+
+	index := make(map[string]int, len(s.Counters))
+	for i, c := range s.Counters {
+		index[c.Item] = i
+	}
+
+	n := len(s.Counters)
+	merged := make([]bool, n)
+	for _, c := range o.Counters {
+		i, ok := index[c.Item]
+		if !ok {
+			s.Counters = append(s.Counters, Counter{Item: c.Item, Count: c.Count + sMin, Error: c.Error + sMin})
+			continue
+		}
+
+		s.Counters[i].Count += c.Count
+		s.Counters[i].Error += c.Error
+		merged[i] = true
+	}
+
+	for i := 0; i < n; i++ {
+		if !merged[i] {
+			s.Counters[i].Count += oMin
+			s.Counters[i].Error += oMin
+		}
+	}
+
+	s.Counters = s.Top(s.Capacity)
+}
+
+// minCount returns the most an item which isn't monitored can weigh, i.e. the lightest count if the sketch is full.
+func (s *Sketch) minCount() int64 {
+	if len(s.Counters) == 0 || len(s.Counters) < s.Capacity {
+		return 0
+	}
+
+	min := s.Counters[0].Count
+	for _, c := range s.Counters[1:] {
+		if c.Count < min {
+			min = c.Count
+		}
+	}
+
+	return min
+}
+
+// Top returns at most k heaviest counters in descending order of their counts.
+func (s *Sketch) Top(k int) []Counter {
+	res := make([]Counter, len(s.Counters))
+	copy(res, s.Counters)
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+
+		return res[i].Item < res[j].Item
+	})
+
+	if len(res) > k {
+		return res[:k]
+	}
+
+	return res
+}
@@ -0,0 +1,112 @@
+package topk
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"reflect"
+	"testing"
+)
+
+type weightedItem struct {
+	item string
+	w    int64
+}
+
+func TestSketchAdd(t *testing.T) {
+	ts := []struct {
+		name     string
+		capacity int
+		items    []weightedItem
+		k        int
+		expected []Counter
+	}{
+		{
+			name:     "Counts are exact while within capacity",
+			capacity: 3,
+			items:    []weightedItem{{"Nike", 1}, {"Adidas", 2}, {"Nike", 3}, {"Puma", 1}},
+			k:        3,
+			expected: []Counter{{Item: "Nike", Count: 4}, {Item: "Adidas", Count: 2}, {Item: "Puma", Count: 1}},
+		},
+		{
+			name:     "Lightest item is evicted and its count becomes the error bound",
+			capacity: 2,
+			items:    []weightedItem{{"Nike", 5}, {"Adidas", 2}, {"Puma", 1}},
+			k:        2,
+			expected: []Counter{{Item: "Nike", Count: 5}, {Item: "Puma", Count: 3, Error: 2}},
+		},
+		{
+			name:     "Only k heaviest items are returned",
+			capacity: 3,
+			items:    []weightedItem{{"Nike", 1}, {"Adidas", 2}, {"Puma", 3}},
+			k:        1,
+			expected: []Counter{{Item: "Puma", Count: 3}},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewSketch(test.capacity)
+			for _, wi := range test.items {
+				s.Add(wi.item, wi.w)
+			}
+
+			res := s.Top(test.k)
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %s", cmp.Diff(test.expected, res))
+			}
+		})
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	ts := []struct {
+		name     string
+		capacity int
+		left     []weightedItem
+		right    []weightedItem
+		expected []Counter
+	}{
+		{
+			name:     "Counts of sketches which aren't full are summed exactly",
+			capacity: 3,
+			left:     []weightedItem{{"Nike", 3}, {"Adidas", 2}},
+			right:    []weightedItem{{"Adidas", 4}, {"Puma", 1}},
+			expected: []Counter{{Item: "Adidas", Count: 6}, {Item: "Nike", Count: 3}, {Item: "Puma", Count: 1}},
+		},
+		{
+			name:     "Items missing from a full sketch are counted with its minimum count",
+			capacity: 2,
+			left:     []weightedItem{{"Nike", 3}, {"Adidas", 2}},
+			right:    []weightedItem{{"Adidas", 4}, {"Puma", 1}},
+			expected: []Counter{{Item: "Adidas", Count: 6}, {Item: "Nike", Count: 4, Error: 1}},
+		},
+		{
+			name:     "Error bounds are summed",
+			capacity: 1,
+			left:     []weightedItem{{"Nike", 5}, {"Puma", 1}},
+			right:    []weightedItem{{"Adidas", 2}},
+			expected: []Counter{{Item: "Adidas", Count: 8, Error: 6}},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewSketch(test.capacity)
+			for _, wi := range test.left {
+				s.Add(wi.item, wi.w)
+			}
+			o := NewSketch(test.capacity)
+			for _, wi := range test.right {
+				o.Add(wi.item, wi.w)
+			}
+
+			s.Merge(*o)
+
+			res := s.Top(test.capacity)
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed results differ: %s", cmp.Diff(test.expected, res))
+			}
+		})
+	}
+}
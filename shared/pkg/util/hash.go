@@ -3,10 +3,22 @@ package util
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
 	"time"
 )
 
+// FilterValue qualifies a filter value with the column it applies to,
+// so that equal values of different columns (e.g. country "TV" and device "TV") don't produce the same hash.
+func FilterValue(column api.AggregateColumn, value string) string {
+	return fmt.Sprintf("%s=%s;", column, value)
+}
+
+// GetBucketKey returns the key of a bucket in tables which aren't broken down by action or dimensions.
+func GetBucketKey(bucket time.Time) string {
+	return bucket.Format("2006-01-02T15:04:05")
+}
+
 func GetAggregateHash(bucket time.Time, action api.Action, filters ...string) string {
 	ret := bucket.Format("2006-01-02T15:04:05") + action.String()
 	for i := range filters {
@@ -16,3 +28,10 @@ func GetAggregateHash(bucket time.Time, action api.Action, filters ...string) st
 	h.Write([]byte(ret))
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+// GetLegacyAggregateHash returns the key aggregates had before their filter values were qualified with FilterValue.
+// Only the origin, brand and category were aggregated then. It's read alongside the current key until the aggregates
+// written in the legacy format fall out of retention, and can be removed afterwards.
+func GetLegacyAggregateHash(bucket time.Time, action api.Action, origin string, brandID string, categoryID string) string {
+	return GetAggregateHash(bucket, action, origin, brandID, categoryID)
+}
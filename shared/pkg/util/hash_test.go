@@ -38,3 +38,73 @@ func TestGetAggregateHash(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterValue(t *testing.T) {
+	bucket := time.Now()
+
+	ts := []struct {
+		name  string
+		left  []string
+		right []string
+	}{
+		{
+			name:  "Equal values of different columns produce different hashes",
+			left:  []string{FilterValue("country", "TV")},
+			right: []string{FilterValue("device", "TV")},
+		},
+		{
+			name:  "Values split differently across columns produce different hashes",
+			left:  []string{FilterValue("origin", "AB"), FilterValue("brand_id", "")},
+			right: []string{FilterValue("origin", "A"), FilterValue("brand_id", "B")},
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			left := GetAggregateHash(bucket, api.VIEW, test.left...)
+			right := GetAggregateHash(bucket, api.VIEW, test.right...)
+			if left == right {
+				t.Errorf("computed hashes are equal")
+			}
+		})
+	}
+}
+
+func TestGetLegacyAggregateHash(t *testing.T) {
+	bucket := time.Now()
+
+	ts := []struct {
+		name     string
+		origin   string
+		brandID  string
+		category string
+		expected string
+	}{
+		{
+			name:     "Values are concatenated unqualified",
+			origin:   "Ad",
+			brandID:  "Nike",
+			expected: GetAggregateHash(bucket, api.VIEW, "AdNike"),
+		},
+		{
+			name:     "Legacy and qualified keys differ",
+			brandID:  "Nike",
+			category: "Knitwear",
+			expected: GetAggregateHash(bucket, api.VIEW, "NikeKnitwear"),
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			res := GetLegacyAggregateHash(bucket, api.VIEW, test.origin, test.brandID, test.category)
+			if !reflect.DeepEqual(test.expected, res) {
+				t.Errorf("expected and computed values differ")
+			}
+			if res == GetAggregateHash(bucket, api.VIEW, FilterValue("origin", test.origin), FilterValue("brand_id", test.brandID), FilterValue("category_id", test.category)) {
+				t.Errorf("expected the legacy key to differ from the qualified one")
+			}
+		})
+	}
+}
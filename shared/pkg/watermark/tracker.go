@@ -0,0 +1,121 @@
+package watermark
+
+import (
+	"fmt"
+	"github.com/lovoo/goka"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/api"
+	"github.com/rzetelskik/allezon-analytics/shared/pkg/kafka"
+	"sync"
+	"time"
+)
+
+// reportInterval limits how often a partition's watermark is published.
+const reportInterval = time.Second
+
+type LatePolicy string
+
+const (
+	// LatePolicyDrop discards late user tags.
+	LatePolicyDrop LatePolicy = "drop"
+	// LatePolicyCount keeps late user tags apart from the on-time ones.
+	LatePolicyCount LatePolicy = "count"
+)
+
+func ParseLatePolicy(s string) (LatePolicy, error) {
+	switch p := LatePolicy(s); p {
+	case LatePolicyDrop, LatePolicyCount:
+		return p, nil
+	default:
+		return LatePolicy(""), fmt.Errorf("%q is not a valid late policy", s)
+	}
+}
+
+type partition struct {
+	maxEventTime time.Time
+	reported     time.Time
+}
+
+// Tracker keeps per-partition watermarks derived from the event time of processed user tags.
+// A partition's watermark trails the maximum event time it has seen by the allowed lateness.
+type Tracker struct {
+	allowedLateness time.Duration
+
+	mu         sync.Mutex
+	partitions map[int32]*partition
+}
+
+func NewTracker(allowedLateness time.Duration) *Tracker {
+	return &Tracker{
+		allowedLateness: allowedLateness,
+		partitions:      make(map[int32]*partition),
+	}
+}
+
+func (t *Tracker) get(p int32) *partition {
+	s, ok := t.partitions[p]
+	if !ok {
+		s = &partition{}
+		t.partitions[p] = s
+	}
+
+	return s
+}
+
+func (t *Tracker) watermark(s *partition) time.Time {
+	if s.maxEventTime.IsZero() {
+		return time.Time{}
+	}
+
+	return s.maxEventTime.Add(-t.allowedLateness)
+}
+
+// Observe advances the watermark of partition p with the event time et
+// and returns whether et was behind the watermark before the update.
+func (t *Tracker) Observe(p int32, et time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.get(p)
+	late := et.Before(t.watermark(s))
+	if et.After(s.maxEventTime) {
+		s.maxEventTime = et
+	}
+
+	return late
+}
+
+func (t *Tracker) Watermark(p int32) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.watermark(t.get(p))
+}
+
+// Report returns the watermark of partition p and whether it moved far enough since the last report to be published.
+func (t *Tracker) Report(p int32) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.get(p)
+	w := t.watermark(s)
+	if w.IsZero() || w.Sub(s.reported) < reportInterval {
+		return w, false
+	}
+	s.reported = w
+
+	return w, true
+}
+
+// Emit publishes the watermark of the context's partition to kafka.WatermarkTopic when due.
+// The topic has to be declared as an output of the calling processor.
+func (t *Tracker) Emit(ctx goka.Context, group goka.Group) {
+	w, ok := t.Report(ctx.Partition())
+	if !ok {
+		return
+	}
+
+	ctx.Emit(kafka.WatermarkTopic, string(group), api.Watermark{
+		Partition: ctx.Partition(),
+		Time:      w,
+	})
+}
@@ -0,0 +1,91 @@
+package watermark
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrackerObserve(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 15, 0, 0, time.UTC)
+
+	ts := []struct {
+		name            string
+		allowedLateness time.Duration
+		eventTimes      []time.Time
+		expectedLate    []bool
+		expected        time.Time
+	}{
+		{
+			name:            "In-order user tags are never late",
+			allowedLateness: 0,
+			eventTimes:      []time.Time{base, base.Add(time.Second), base.Add(2 * time.Second)},
+			expectedLate:    []bool{false, false, false},
+			expected:        base.Add(2 * time.Second),
+		},
+		{
+			name:            "User tags within the allowed lateness are not late",
+			allowedLateness: time.Minute,
+			eventTimes:      []time.Time{base.Add(time.Minute), base, base.Add(30 * time.Second)},
+			expectedLate:    []bool{false, false, false},
+			expected:        base,
+		},
+		{
+			name:            "User tags behind the watermark are late and don't move it back",
+			allowedLateness: time.Minute,
+			eventTimes:      []time.Time{base.Add(5 * time.Minute), base.Add(time.Minute), base.Add(4 * time.Minute)},
+			expectedLate:    []bool{false, true, false},
+			expected:        base.Add(4 * time.Minute),
+		},
+	}
+
+	t.Parallel()
+	for _, test := range ts {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := NewTracker(test.allowedLateness)
+
+			late := make([]bool, 0, len(test.eventTimes))
+			for _, et := range test.eventTimes {
+				late = append(late, tracker.Observe(0, et))
+			}
+
+			if !reflect.DeepEqual(test.expectedLate, late) {
+				t.Errorf("expected and computed lateness differ: %v != %v", test.expectedLate, late)
+			}
+
+			res := tracker.Watermark(0)
+			if !test.expected.Equal(res) {
+				t.Errorf("expected and computed watermarks differ: %v != %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestTrackerReport(t *testing.T) {
+	base := time.Date(2022, 3, 22, 12, 15, 0, 0, time.UTC)
+
+	tracker := NewTracker(0)
+
+	_, ok := tracker.Report(0)
+	if ok {
+		t.Errorf("empty partition shouldn't be reported")
+	}
+
+	tracker.Observe(0, base)
+	w, ok := tracker.Report(0)
+	if !ok || !w.Equal(base) {
+		t.Errorf("expected watermark %v to be reported, got %v", base, w)
+	}
+
+	tracker.Observe(0, base.Add(time.Millisecond))
+	_, ok = tracker.Report(0)
+	if ok {
+		t.Errorf("watermark shouldn't be reported before it advances by the report interval")
+	}
+
+	tracker.Observe(0, base.Add(reportInterval))
+	_, ok = tracker.Report(0)
+	if !ok {
+		t.Errorf("watermark should be reported after it advances by the report interval")
+	}
+}